@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/kpurdon/tidygit/engine"
+)
+
+// appModel is the full-screen UI for `tidygit all`. It owns the terminal for
+// the whole run: a repo list pane, the current repo's activity pane and a
+// prompt area. The engine runs on its own goroutine and reaches the model
+// only through messages, so nothing else ever writes to the terminal.
+type appModel struct {
+	repos    []appRepo
+	current  int
+	activity []string
+	renderer eventRenderer
+
+	// step is the label of the long-running step in progress, if any.
+	step  string
+	frame int
+
+	prompt *confirmModel
+	reply  chan<- promptReply
+
+	checklist   *checklistModel
+	selectReply chan<- selectReply
+
+	width, height int
+	// stop ends the run after the current repo; cancel also interrupts it.
+	stop       context.CancelFunc
+	cancel     context.CancelFunc
+	stopping   bool
+	cancelling bool
+}
+
+type repoState int
+
+const (
+	repoPending repoState = iota
+	repoRunning
+	repoDone
+)
+
+type appRepo struct {
+	name   string
+	state  repoState
+	result engine.Result
+}
+
+type (
+	repoStartedMsg  struct{ index int }
+	repoFinishedMsg struct {
+		index  int
+		result engine.Result
+	}
+	eventMsg  struct{ event engine.Event }
+	promptMsg struct {
+		prompt engine.Prompt
+		reply  chan<- promptReply
+	}
+	selectMsg struct {
+		prompts []engine.Prompt
+		reply   chan<- selectReply
+	}
+	runFinishedMsg struct{}
+	tickMsg        struct{}
+)
+
+type promptReply struct {
+	answer engine.Answer
+	err    error
+}
+
+type selectReply struct {
+	values []bool
+	err    error
+}
+
+const (
+	repoPaneWidth = 32
+	// minSplitWidth is the narrowest terminal that still gets the repo pane.
+	minSplitWidth = 72
+)
+
+func newAppModel(repoPaths []string, stop, cancel context.CancelFunc) appModel {
+	repos := make([]appRepo, len(repoPaths))
+	for i, p := range repoPaths {
+		repos[i] = appRepo{name: filepath.Base(p)}
+	}
+	return appModel{repos: repos, current: -1, stop: stop, cancel: cancel}
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(80*time.Millisecond, func(time.Time) tea.Msg {
+		return tickMsg{}
+	})
+}
+
+func (m appModel) Init() tea.Cmd {
+	return tick()
+}
+
+func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		if m.checklist != nil {
+			m.checklist.height = m.bodyHeight()
+			m.checklist.scroll()
+		}
+	case tickMsg:
+		m.frame++
+		return m, tick()
+	case repoStartedMsg:
+		m.current = msg.index
+		m.repos[msg.index].state = repoRunning
+		m.activity = nil
+		m.renderer = eventRenderer{}
+	case repoFinishedMsg:
+		m.repos[msg.index].state = repoDone
+		m.repos[msg.index].result = msg.result
+		m.step = ""
+	case eventMsg:
+		switch msg.event.Type {
+		case engine.EventStepStarted:
+			m.step = stepLabel(msg.event.Step)
+		case engine.EventStepFinished:
+			m.step = ""
+		}
+		m.activity = append(m.activity, m.renderer.render(msg.event)...)
+	case promptMsg:
+		cm := newConfirmModel(msg.prompt)
+		m.prompt = &cm
+		m.reply = msg.reply
+	case branchDetailsMsg, browserOpenedMsg:
+		if m.prompt != nil {
+			updated, _ := m.prompt.Update(msg)
+			cm := updated.(confirmModel)
+			m.prompt = &cm
+		}
+	case selectMsg:
+		cl := newChecklistModel(msg.prompts)
+		cl.height = m.bodyHeight()
+		m.checklist = &cl
+		m.selectReply = msg.reply
+	case runFinishedMsg:
+		return m, tea.Quit
+	case tea.KeyMsg:
+		if m.prompt != nil {
+			return m.updatePrompt(msg)
+		}
+		if m.checklist != nil {
+			return m.updateChecklist(msg), nil
+		}
+		// Let the engine wind down; runFinishedMsg quits the program.
+		switch msg.String() {
+		case "q":
+			m.stopping = true
+			m.stop()
+		case "ctrl+c":
+			m.cancelling = true
+			m.cancel()
+		}
+	}
+	return m, nil
+}
+
+// updatePrompt forwards a key to the active prompt and answers the engine
+// once the prompt is settled. Commands from the prompt are only kept while it
+// is still open, since a settled prompt returns tea.Quit.
+func (m appModel) updatePrompt(msg tea.KeyMsg) (appModel, tea.Cmd) {
+	updated, cmd := m.prompt.Update(msg)
+	p := updated.(confirmModel)
+
+	switch {
+	case p.aborted:
+		m.reply <- promptReply{answer: engine.AnswerQuit}
+	case p.done:
+		m.reply <- promptReply{answer: p.answer}
+	default:
+		m.prompt = &p
+		return m, cmd
+	}
+
+	m.prompt = nil
+	m.reply = nil
+	return m, nil
+}
+
+// updateChecklist forwards a key to the active checklist and answers the
+// engine once a selection is applied or cancelled.
+func (m appModel) updateChecklist(msg tea.KeyMsg) appModel {
+	updated, _ := m.checklist.Update(msg)
+	cl := updated.(checklistModel)
+
+	switch {
+	case cl.aborted:
+		m.selectReply <- selectReply{err: engine.ErrAborted}
+	case cl.done:
+		m.selectReply <- selectReply{values: cl.selected()}
+	default:
+		m.checklist = &cl
+		return m
+	}
+
+	m.checklist = nil
+	m.selectReply = nil
+	return m
+}
+
+// bodyHeight is the height of the panes between the header and footer.
+func (m appModel) bodyHeight() int {
+	// Header, blank line, body, blank line, footer.
+	return max(m.height-3-lipgloss.Height(m.renderFooter()), 1)
+}
+
+func (m appModel) View() tea.View {
+	v := tea.NewView(m.render())
+	v.AltScreen = true
+	return v
+}
+
+func (m appModel) render() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := brandLine()
+	if m.current >= 0 {
+		header += dimStyle.Render(fmt.Sprintf("  [%d/%d]", m.current+1, len(m.repos)))
+	}
+
+	footer := m.renderFooter()
+	bodyHeight := m.bodyHeight()
+
+	activityWidth := m.width
+	var body string
+	if m.width >= minSplitWidth {
+		activityWidth = m.width - repoPaneWidth - 1
+		repos := lipgloss.NewStyle().
+			Width(repoPaneWidth).
+			Height(bodyHeight).
+			MaxHeight(bodyHeight).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderRight(true).
+			BorderForeground(borderColor).
+			Render(m.renderRepos(bodyHeight))
+		body = lipgloss.JoinHorizontal(lipgloss.Top, repos, m.renderMain(activityWidth, bodyHeight))
+	} else {
+		body = m.renderMain(activityWidth, bodyHeight)
+	}
+
+	return header + "\n\n" + body + "\n\n" + footer
+}
+
+func (m appModel) spinnerFrame() string {
+	return okStyle.Render(spinnerFrames[m.frame%len(spinnerFrames)])
+}
+
+// renderRepos renders the repo list, scrolled so the current repo is visible.
+func (m appModel) renderRepos(height int) string {
+	start := 0
+	if m.current >= height {
+		start = m.current - height + 1
+	}
+	end := min(start+height, len(m.repos))
+
+	nameStyle := lipgloss.NewStyle().MaxWidth(repoPaneWidth - 4)
+	var lines []string
+	for _, r := range m.repos[start:end] {
+		var icon string
+		name := nameStyle.Render(r.name)
+		switch r.state {
+		case repoPending:
+			icon = dimStyle.Render("·")
+			name = dimStyle.Render(name)
+		case repoRunning:
+			icon = m.spinnerFrame()
+			name = sectionStyle.Render(name)
+		case repoDone:
+			icon = okStyle.Render("✓")
+			if len(r.result.Errors) > 0 {
+				icon = errStyle.Render("✗")
+			}
+		}
+		lines = append(lines, "  "+icon+" "+name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderMain renders the checklist while one is active, otherwise the
+// current repo's activity.
+func (m appModel) renderMain(width, height int) string {
+	if m.checklist != nil {
+		return m.checklist.render(width)
+	}
+	return m.renderActivity(width, height)
+}
+
+// renderActivity renders the tail of the current repo's output.
+func (m appModel) renderActivity(width, height int) string {
+	lines := m.activity
+	if m.step != "" {
+		lines = append(lines[:len(lines):len(lines)], fmt.Sprintf("  %s %s...", m.spinnerFrame(), m.step))
+	}
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+
+	lineStyle := lipgloss.NewStyle().MaxWidth(width - 1)
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = " " + lineStyle.Render(l)
+	}
+	return strings.Join(out, "\n")
+}
+
+func (m appModel) renderFooter() string {
+	switch {
+	case m.prompt != nil:
+		return m.prompt.render()
+	case m.checklist != nil:
+		return ""
+	case m.cancelling:
+		return dimStyle.Render("  Cancelling...")
+	case m.stopping:
+		return dimStyle.Render("  Stopping after the current repo... (ctrl+c cancel now)")
+	default:
+		return dimStyle.Render("  q stop after this repo · ctrl+c cancel")
+	}
+}
+
+// runApp cleans every repo in repoPaths under the full-screen app and
+// returns the per-repo results.
+func runApp(ctx context.Context, repoPaths []string, opts cliOptions) ([]engine.Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	p := tea.NewProgram(newAppModel(repoPaths, stop, cancel))
+
+	var results []engine.Result
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		c := &engine.Cleaner{
+			Decide: func(ctx context.Context, prompt engine.Prompt) (engine.Answer, error) {
+				reply := make(chan promptReply, 1)
+				p.Send(promptMsg{prompt: prompt, reply: reply})
+				select {
+				case r := <-reply:
+					return r.answer, r.err
+				case <-ctx.Done():
+					return engine.AnswerQuit, nil
+				}
+			},
+			Observer: engine.Observers(
+				engine.ObserverFunc(func(e engine.Event) { p.Send(eventMsg{event: e}) }),
+				opts.events,
+			),
+		}
+
+		if opts.checklist {
+			c.Select = func(ctx context.Context, prompts []engine.Prompt) ([]bool, error) {
+				reply := make(chan selectReply, 1)
+				p.Send(selectMsg{prompts: prompts, reply: reply})
+				select {
+				case r := <-reply:
+					return r.values, r.err
+				case <-ctx.Done():
+					return nil, engine.ErrAborted
+				}
+			}
+		}
+
+		for i, repoPath := range repoPaths {
+			if stopCtx.Err() != nil {
+				break
+			}
+			p.Send(repoStartedMsg{index: i})
+			result := runRepo(ctx, c, repoPath, opts)
+			results = append(results, result)
+			p.Send(repoFinishedMsg{index: i, result: result})
+			if result.Quit {
+				break
+			}
+		}
+		p.Send(runFinishedMsg{})
+	}()
+
+	_, err := p.Run()
+	// Unblock the engine if the program exited before the run finished.
+	cancel()
+	<-done
+	return results, err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+type browserOpenedMsg struct {
+	err error
+}
+
+// openURL opens url with $BROWSER, or the platform's default opener when
+// $BROWSER is unset. Like xdg-utils, $BROWSER may list several commands
+// separated by colons; the first one that starts wins.
+func openURL(url string) error {
+	var candidates [][]string
+	if env := os.Getenv("BROWSER"); env != "" {
+		for _, c := range strings.Split(env, ":") {
+			if fields := strings.Fields(c); len(fields) > 0 {
+				candidates = append(candidates, append(fields, url))
+			}
+		}
+	} else {
+		switch runtime.GOOS {
+		case "darwin":
+			candidates = [][]string{{"open", url}}
+		case "windows":
+			candidates = [][]string{{"rundll32", "url.dll,FileProtocolHandler", url}}
+		default:
+			candidates = [][]string{{"xdg-open", url}}
+		}
+	}
+
+	var lastErr error
+	for _, c := range candidates {
+		cmd := exec.Command(c[0], c[1:]...)
+		if err := cmd.Start(); err != nil {
+			lastErr = err
+			continue
+		}
+		// Reap the opener without blocking the prompt.
+		go cmd.Wait()
+		return nil
+	}
+	return fmt.Errorf("opening %s: %w", url, lastErr)
+}
+
+// openURLCmd opens url in the background and reports the outcome.
+func openURLCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		return browserOpenedMsg{err: openURL(url)}
+	}
+}
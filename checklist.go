@@ -0,0 +1,436 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/kpurdon/tidygit/engine"
+)
+
+// checklistModel lists every worktree and branch of a repo at once so they
+// can be toggled and applied in one go. Items start checked when the
+// one-by-one prompt would default to yes.
+type checklistModel struct {
+	items []checklistItem
+	// view holds the indexes into items that pass the filter, in sort
+	// order. cursor and offset index into view.
+	view    []int
+	cursor  int
+	offset  int
+	height  int
+	done    bool
+	aborted bool
+
+	filter    string
+	filtering bool
+	sort      checklistSort
+	reverse   bool
+
+	// groups counts the items per branch prefix, largest first.
+	groups []checklistGroup
+}
+
+type checklistGroup struct {
+	prefix string
+	count  int
+}
+
+type checklistItem struct {
+	prompt  engine.Prompt
+	checked bool
+}
+
+// checklistSort is the order of the checklist, cycled with "s".
+type checklistSort int
+
+const (
+	sortDefault checklistSort = iota
+	sortName
+	sortDate
+	sortPR
+	sortAhead
+	numSorts
+)
+
+func (s checklistSort) String() string {
+	switch s {
+	case sortName:
+		return "name"
+	case sortDate:
+		return "last commit"
+	case sortPR:
+		return "PR state"
+	case sortAhead:
+		return "ahead"
+	default:
+		return "default"
+	}
+}
+
+func newChecklistModel(prompts []engine.Prompt) checklistModel {
+	items := make([]checklistItem, len(prompts))
+	for i, p := range prompts {
+		items[i] = checklistItem{prompt: p, checked: p.Default}
+	}
+	m := checklistModel{items: items, groups: countGroups(prompts)}
+	m.refresh()
+	return m
+}
+
+func (m checklistModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m checklistModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.scroll()
+	case tea.KeyMsg:
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
+		switch msg.String() {
+		case "up", "k":
+			m.move(-1)
+		case "down", "j":
+			m.move(1)
+		case "pgup":
+			m.move(-m.listHeight())
+		case "pgdown":
+			m.move(m.listHeight())
+		case "home", "g":
+			m.move(-len(m.view))
+		case "end", "G":
+			m.move(len(m.view))
+		case "space", " ", "x":
+			if len(m.view) > 0 {
+				it := &m.items[m.view[m.cursor]]
+				it.checked = !it.checked
+			}
+		case "a":
+			m.toggleGroup(func(checklistItem) bool { return true })
+		case "m":
+			m.toggleGroup(func(it checklistItem) bool { return it.prompt.Merged })
+		case "w":
+			m.toggleGroup(func(it checklistItem) bool { return it.prompt.Kind == engine.PromptWorktree })
+		case "b":
+			m.toggleGroup(func(it checklistItem) bool { return it.prompt.Kind == engine.PromptBranch })
+		case "p":
+			if len(m.view) == 0 {
+				break
+			}
+			if prefix := engine.BranchGroup(m.items[m.view[m.cursor]].prompt.Branch); prefix != "" {
+				m.toggleGroup(func(it checklistItem) bool { return engine.BranchGroup(it.prompt.Branch) == prefix })
+			}
+		case "/":
+			m.filtering = true
+		case "s":
+			m.sort = (m.sort + 1) % numSorts
+			m.refresh()
+		case "S":
+			m.reverse = !m.reverse
+			m.refresh()
+		case "enter":
+			m.done = true
+			return m, tea.Quit
+		case "esc":
+			if m.filter != "" {
+				m.filter = ""
+				m.refresh()
+				break
+			}
+			// Cancel: keep everything in this repo.
+			for i := range m.items {
+				m.items[i].checked = false
+			}
+			m.done = true
+			return m, tea.Quit
+		case "ctrl+c":
+			m.aborted = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// updateFilter edits the filter while filter input is active.
+func (m checklistModel) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.filtering = false
+	case "esc":
+		m.filtering = false
+		m.filter = ""
+	case "backspace":
+		if r := []rune(m.filter); len(r) > 0 {
+			m.filter = string(r[:len(r)-1])
+		}
+	case "ctrl+c":
+		m.aborted = true
+		return m, tea.Quit
+	default:
+		m.filter += msg.Key().Text
+	}
+	m.refresh()
+	return m, nil
+}
+
+// refresh rebuilds the filtered, sorted view and clamps the cursor.
+func (m *checklistModel) refresh() {
+	m.view = make([]int, 0, len(m.items))
+	for i, it := range m.items {
+		if fuzzyMatch(m.filter, itemName(it.prompt)) {
+			m.view = append(m.view, i)
+		}
+	}
+
+	slices.SortStableFunc(m.view, func(a, b int) int {
+		pa, pb := m.items[a].prompt, m.items[b].prompt
+		var c int
+		switch m.sort {
+		case sortName:
+			c = cmp.Compare(itemName(pa), itemName(pb))
+		case sortDate:
+			c = pa.LastCommit.Compare(pb.LastCommit)
+		case sortPR:
+			c = cmp.Compare(prRank(pa.PR), prRank(pb.PR))
+		case sortAhead:
+			c = cmp.Compare(pa.Ahead, pb.Ahead)
+		default:
+			c = cmp.Compare(a, b)
+		}
+		if m.reverse {
+			return -c
+		}
+		return c
+	})
+
+	m.move(0)
+}
+
+// prRank orders PR states from most to least safely deletable.
+func prRank(pr *engine.PR) int {
+	if pr == nil {
+		return 3
+	}
+	switch pr.State {
+	case "MERGED":
+		return 0
+	case "CLOSED":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// countGroups counts prompts per branch prefix, largest group first.
+func countGroups(prompts []engine.Prompt) []checklistGroup {
+	counts := make(map[string]int)
+	for _, p := range prompts {
+		if g := engine.BranchGroup(p.Branch); g != "" {
+			counts[g]++
+		}
+	}
+
+	groups := make([]checklistGroup, 0, len(counts))
+	for prefix, count := range counts {
+		groups = append(groups, checklistGroup{prefix: prefix, count: count})
+	}
+	slices.SortFunc(groups, func(a, b checklistGroup) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), cmp.Compare(a.prefix, b.prefix))
+	})
+	return groups
+}
+
+// itemName is the text an item is filtered and sorted by.
+func itemName(p engine.Prompt) string {
+	if p.Kind == engine.PromptWorktree {
+		if p.Branch != "" {
+			return p.Worktree + " (" + p.Branch + ")"
+		}
+		return p.Worktree
+	}
+	return p.Branch
+}
+
+func (m *checklistModel) move(delta int) {
+	m.cursor = max(0, min(len(m.view)-1, m.cursor+delta))
+	m.scroll()
+}
+
+// scroll adjusts the offset so the cursor stays visible.
+func (m *checklistModel) scroll() {
+	rows := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+// toggleGroup checks every visible item in the group, or unchecks them all
+// if they are already checked.
+func (m *checklistModel) toggleGroup(inGroup func(checklistItem) bool) {
+	allChecked := true
+	for _, i := range m.view {
+		if inGroup(m.items[i]) && !m.items[i].checked {
+			allChecked = false
+			break
+		}
+	}
+	for _, i := range m.view {
+		if inGroup(m.items[i]) {
+			m.items[i].checked = !allChecked
+		}
+	}
+}
+
+// listHeight is the number of rows available for items.
+func (m checklistModel) listHeight() int {
+	// Title, groups, blank, items, blank, help.
+	return max(m.height-5, 3)
+}
+
+// selected returns the decision for every item, in the original order.
+func (m checklistModel) selected() []bool {
+	out := make([]bool, len(m.items))
+	for i, it := range m.items {
+		out[i] = it.checked
+	}
+	return out
+}
+
+func (m checklistModel) View() tea.View {
+	v := tea.NewView(m.render(0))
+	v.AltScreen = true
+	return v
+}
+
+// render draws the checklist. A width of 0 leaves lines untruncated.
+func (m checklistModel) render(width int) string {
+	end := min(m.offset+m.listHeight(), len(m.view))
+
+	count := 0
+	for _, it := range m.items {
+		if it.checked {
+			count++
+		}
+	}
+
+	lineStyle := lipgloss.NewStyle()
+	if width > 0 {
+		lineStyle = lineStyle.MaxWidth(width)
+	}
+
+	title := sectionStyle.Render("  Select items to remove") +
+		dimStyle.Render(fmt.Sprintf("  %d of %d selected · sort: %s", count, len(m.items), m.sort))
+	if m.reverse {
+		title += dimStyle.Render(" (reversed)")
+	}
+	switch {
+	case m.filtering:
+		title += "  " + warnStyle.Render("/"+m.filter+"█")
+	case m.filter != "":
+		title += "  " + warnStyle.Render("/"+m.filter) + dimStyle.Render(fmt.Sprintf(" (%d shown)", len(m.view)))
+	}
+
+	groups := make([]string, len(m.groups))
+	for i, g := range m.groups {
+		groups[i] = fmt.Sprintf("%s %d", g.prefix, g.count)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, "none")
+	}
+
+	lines := []string{
+		lineStyle.Render(title),
+		lineStyle.Render(dimStyle.Render("  groups: " + strings.Join(groups, " · "))),
+		"",
+	}
+	if len(m.view) == 0 {
+		lines = append(lines, dimLine("No matches"))
+	}
+	for i := m.offset; i < end; i++ {
+		lines = append(lines, lineStyle.Render(m.renderItem(i)))
+	}
+
+	help := "  ↑/↓ move · space toggle · a all · m merged · w worktrees · b branches · p prefix group · / filter · s sort · S reverse · enter apply · esc keep all · ctrl+c quit"
+	if m.filtering {
+		help = "  type to filter · enter done · esc clear"
+	}
+	lines = append(lines, "", lineStyle.Render(dimStyle.Render(help)))
+	return strings.Join(lines, "\n")
+}
+
+// renderItem renders the row at position i of the view.
+func (m checklistModel) renderItem(i int) string {
+	it := m.items[m.view[i]]
+	p := it.prompt
+
+	cursor := "  "
+	if i == m.cursor {
+		cursor = itemStyle.Render("▸ ")
+	}
+	box := dimStyle.Render("[ ]")
+	if it.checked {
+		box = errStyle.Render("[x]")
+	}
+
+	kind := dimStyle.Render("br")
+	if p.Kind == engine.PromptWorktree {
+		kind = dimStyle.Render("wt")
+	}
+
+	pr := dimStyle.Render(fmt.Sprintf("%-12s", "no PR"))
+	if p.PR != nil {
+		label := fmt.Sprintf("#%d", p.PR.Number)
+		pr = prStyle.Render(label) + " " + styledPRState(p.PR.State) +
+			strings.Repeat(" ", max(0, 11-len(label)-len(p.PR.State)))
+	}
+
+	age := dimStyle.Render(fmt.Sprintf("%4s", formatAge(p.LastCommit)))
+	if p.Class.Stale {
+		age = warnStyle.Render(fmt.Sprintf("%4s", formatAge(p.LastCommit)))
+	}
+
+	ahead := "   ?"
+	if p.Ahead >= 0 {
+		ahead = fmt.Sprintf("%+4d", p.Ahead)
+	}
+
+	return fmt.Sprintf("%s%s %s %s %s %s %s  %s",
+		cursor, box, kind, pr,
+		age,
+		dimStyle.Render(ahead),
+		statusBadge(p.Class.Status, statusBadgeWidth), itemStyle.Render(itemName(p)))
+}
+
+// selectPrompts shows the checklist for prompts and returns the decisions.
+// It returns engine.ErrAborted if the user quits with Ctrl+C.
+func selectPrompts(ctx context.Context, prompts []engine.Prompt) ([]bool, error) {
+	if !canPrompt(promptOutput) {
+		return nil, ErrNoTerminal
+	}
+
+	p := tea.NewProgram(newChecklistModel(prompts), tea.WithOutput(promptOutput), tea.WithContext(ctx))
+	result, err := p.Run()
+	if err != nil {
+		if errors.Is(err, tea.ErrProgramKilled) {
+			return nil, engine.ErrAborted
+		}
+		return nil, err
+	}
+
+	final := result.(checklistModel)
+	if final.aborted {
+		return nil, engine.ErrAborted
+	}
+	return final.selected(), nil
+}
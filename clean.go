@@ -1,290 +1,242 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
-)
-
-type repoResult struct {
-	Name             string
-	DefaultBranch    string
-	WorktreesTotal   int
-	WorktreesRemoved int
-	WorktreesSkipped int
-	BranchesTotal    int
-	BranchesDeleted  int
-	BranchesSkipped  int
-	PRsFound         int
-	Errors           []string
-}
+	"time"
 
-func (r *repoResult) addErr(msg string, err error) {
-	r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", msg, err))
-	uiErr(fmt.Sprintf("%s: %v", msg, err))
-}
+	"charm.land/lipgloss/v2"
+	"github.com/kpurdon/tidygit/engine"
+)
 
-// isMerged returns true if the branch has a merged PR.
-func isMerged(prs map[string]PR, branch string) bool {
-	pr, hasPR := prs[branch]
-	return hasPR && pr.State == "MERGED"
+// eventRenderer turns engine events into the styled lines of the human UI.
+// It is shared by the inline output of single-repo runs and the activity
+// pane of the full-screen app.
+type eventRenderer struct {
+	// inItem is set while an item's output block is open so the next block
+	// can be separated by a blank line.
+	inItem bool
+	// itemIsWorktree is set when the open item is a worktree, whose branch
+	// deletion is reported by name.
+	itemIsWorktree bool
 }
 
-func clean(dir string, showBrand bool, auto bool) repoResult {
-	absDir, err := filepath.Abs(dir)
-	if err != nil {
-		return repoResult{Errors: []string{fmt.Sprintf("resolving path: %v", err)}}
-	}
-
-	if err := os.Chdir(absDir); err != nil {
-		return repoResult{Errors: []string{fmt.Sprintf("changing to directory %s: %v", absDir, err)}}
-	}
-
-	repoName := filepath.Base(absDir)
-	result := repoResult{Name: repoName}
-
-	// Detect default branch
-	defaultBranch, err := gitDefaultBranch()
-	if err != nil {
-		result.addErr("detecting default branch", err)
-	} else {
-		result.DefaultBranch = defaultBranch
+func (r *eventRenderer) endItem() []string {
+	if r.inItem {
+		r.inItem = false
+		return []string{""}
 	}
+	return nil
+}
 
-	if showBrand {
-		uiBrand()
+func (r *eventRenderer) startItem(text string, pr *engine.PR, class *engine.Classification) []string {
+	lines := append(r.endItem(), itemLine(text))
+	if pr != nil {
+		lines = append(lines, prLines(*pr)...)
 	}
-	if defaultBranch != "" {
-		uiSection(fmt.Sprintf("%s (%s)", repoName, defaultBranch))
-	} else {
-		uiSection(repoName)
+	if class != nil {
+		lines = append(lines, classLines(*class)...)
 	}
+	r.inItem = true
+	return lines
+}
 
-	// Check for uncommitted changes
-	if gitHasUncommittedChanges() {
-		uiWarn("Uncommitted changes detected")
-		if auto {
-			uiSkipped()
-		} else {
-			uiStopProgress()
+func section(text string) []string {
+	return []string{"", sectionLine(text), ""}
+}
 
-			confirmed, err := confirm("Reset HEAD and discard all changes?", false)
-			if errors.Is(err, ErrUserAborted) {
-				return result
-			} else if err != nil {
-				result.addErr("prompting for reset", err)
-			} else if !confirmed {
-				uiSkipped()
-			} else if err := gitResetHard(); err != nil {
-				result.addErr("resetting HEAD", err)
-			} else {
-				uiOK("Reset to HEAD")
-			}
+// render returns the lines to print for e. Step start/finish events render
+// nothing; callers show those as spinners.
+func (r *eventRenderer) render(e engine.Event) []string {
+	switch e.Type {
+	case engine.EventRepoStarted:
+		name := filepath.Base(e.Repo)
+		if e.Branch == "" {
+			return section(name)
 		}
-	}
-
-	// Switch to default branch
-	onDefaultBranch := false
-	if defaultBranch != "" {
-		if err := gitSwitch(defaultBranch); err != nil {
-			result.addErr("switching to "+defaultBranch, err)
-		} else {
-			uiOK("Switched to " + defaultBranch)
-			onDefaultBranch = true
+		lines := section(fmt.Sprintf("%s (%s)", name, e.Branch))
+		if e.Message != "" {
+			lines = append(lines, warnLine("Default branch "+e.Message))
 		}
-	}
+		return lines
+	case engine.EventRenamed:
+		return []string{okLine("Renamed " + e.Message + " to " + e.Branch)}
+	case engine.EventHeadSet:
+		return []string{okLine("Set " + e.Message + "/HEAD to " + e.Branch)}
+	case engine.EventUncommitted:
+		return []string{warnLine("Uncommitted changes detected")}
+	case engine.EventReset:
+		return []string{okLine("Reset to HEAD")}
+	case engine.EventSwitched:
+		return []string{okLine("Switched to " + e.Branch)}
+	case engine.EventFetched:
+		return []string{okLine("Fetched (pruned remotes)")}
+	case engine.EventPulled:
+		return []string{okLine("Pulled " + e.Branch + " (" + e.Message + ")")}
+	case engine.EventPRsLoaded:
+		if e.Message != "" {
+			return []string{okLine(fmt.Sprintf("Found %d PR(s) (%s)", e.Count, e.Message))}
+		}
+		if e.Count > 0 {
+			return []string{okLine(fmt.Sprintf("Found %d PR(s)", e.Count))}
+		}
+	case engine.EventWorktreesListed:
+		if e.Count > 0 {
+			return section(fmt.Sprintf("Worktrees (%d)", e.Count))
+		}
+		return []string{dimLine("No worktrees to clean up")}
+	case engine.EventBranchesListed:
+		lines := r.endItem()
+		if e.Count > 0 {
+			return append(lines, section(fmt.Sprintf("Branches (%d)", e.Count))...)
+		}
+		return append(lines, dimLine("No branches to clean up"))
+	case engine.EventWorktreeFound:
+		r.itemIsWorktree = true
+		if e.Branch != "" {
+			return r.startItem(fmt.Sprintf("%s (branch: %s)", e.Worktree, e.Branch), e.PR, e.Class)
+		}
+		return r.startItem(e.Worktree, e.PR, e.Class)
+	case engine.EventBranchFound:
+		r.itemIsWorktree = false
+		return r.startItem(e.Branch, e.PR, e.Class)
+	case engine.EventWorktreeRemoved:
+		return []string{okLine("Removed worktree")}
+	case engine.EventBranchDeleted:
+		if r.itemIsWorktree {
+			return []string{okLine("Deleted branch " + e.Branch)}
+		}
+		return []string{okLine("Deleted")}
+	case engine.EventSkipped:
+		if e.Step == "repo" {
+			return append(r.endItem(), dimLine("Skipped the rest of this repo"))
+		}
+		return []string{skippedLine()}
+	case engine.EventRemembered:
+		return []string{dimLine("  Keeping " + e.Branch + " " + e.Message)}
+	case engine.EventPluginAction:
+		return []string{okLine(e.Step + ": " + e.Message)}
+	case engine.EventRetrying:
+		return []string{warnLine(fmt.Sprintf("%s attempt %d failed, %s", e.Step, e.Count, e.Message))}
+	case engine.EventHook:
+		return []string{dimLine("  Running " + e.Step + " hook: " + e.Message)}
+	case engine.EventVetoed:
+		return []string{warnLine("Kept " + e.Branch + ", vetoed by hook"), dimLine("  " + e.Message)}
+	case engine.EventQuit:
+		return append(r.endItem(), warnLine("Quit requested, stopping"))
+	case engine.EventError:
+		return []string{errLine(e.Message)}
+	case engine.EventRepoFinished:
+		return append(r.endItem(), "", doneLine(), "")
+	}
+	return nil
+}
 
-	// Fetch all
-	done := uiSpinner("Fetching")
-	err = gitFetchAll()
-	done()
-	if err != nil {
-		result.addErr("fetching", err)
-	} else {
-		uiOK("Fetched (pruned remotes)")
-	}
+// stepLabels are the spinner labels for long-running steps.
+var stepLabels = map[string]string{
+	"fetch": "Fetching",
+	"pull":  "Pulling",
+	"prs":   "Checking PRs",
+}
 
-	// Pull with rebase (only if on default branch)
-	if onDefaultBranch {
-		if err := gitPull(defaultBranch); err != nil {
-			result.addErr("pulling "+defaultBranch, err)
-		} else {
-			uiOK("Pulled " + defaultBranch + " (rebase)")
-		}
+// stepLabel returns the spinner label for step. Plugin steps have none of
+// their own.
+func stepLabel(step string) string {
+	if label, ok := stepLabels[step]; ok {
+		return label
 	}
+	return "Running " + step
+}
+
+// cliObserver prints rendered events inline for single-repo runs.
+type cliObserver struct {
+	eventRenderer
+	stopSpinner func()
+	// step is the label of the spinner, if one is running.
+	step string
+}
 
-	// Fetch PRs
-	done = uiSpinner("Checking PRs")
-	prs, err := ghFetchPRs()
-	done()
-	if err != nil {
-		result.addErr("fetching PRs", err)
-		prs = map[string]PR{}
-	} else {
-		result.PRsFound = len(prs)
-		if len(prs) > 0 {
-			uiOK(fmt.Sprintf("Found %d PR(s)", len(prs)))
+func (o *cliObserver) Observe(e engine.Event) {
+	switch e.Type {
+	case engine.EventStepStarted:
+		o.step = stepLabel(e.Step)
+		o.stopSpinner = uiSpinner(o.step)
+	case engine.EventStepFinished:
+		if o.stopSpinner != nil {
+			o.stopSpinner()
+			o.stopSpinner = nil
 		}
 	}
 
-	// List branches early so we can detect worktree+branch overlap
-	excludeBranch := defaultBranch
-	if excludeBranch == "" {
-		excludeBranch = "__none__"
+	lines := o.render(e)
+	if len(lines) == 0 {
+		return
 	}
-	branches, err := gitListBranches(excludeBranch)
-	if err != nil {
-		result.addErr("listing branches", err)
+	// Lines printed during a step, such as retries, go above the spinner.
+	if o.stopSpinner != nil {
+		o.stopSpinner()
+		defer func() { o.stopSpinner = uiSpinner(o.step) }()
 	}
-
-	branchSet := make(map[string]struct{}, len(branches))
-	for _, b := range branches {
-		branchSet[b] = struct{}{}
+	for _, line := range lines {
+		lipgloss.Println(line)
 	}
+}
 
-	deletedBranches := make(map[string]struct{})
-	skippedBranches := make(map[string]struct{})
-
-	// Prune worktrees
-	if err := gitPruneWorktrees(); err != nil {
-		result.addErr("pruning worktrees", err)
+// decide answers engine prompts with the interactive confirm prompt.
+func decide(ctx context.Context, p engine.Prompt) (engine.Answer, error) {
+	answer, err := confirm(p)
+	if errors.Is(err, ErrUserAborted) {
+		return engine.AnswerQuit, nil
 	}
+	return answer, err
+}
 
-	// List worktrees
-	worktrees, err := gitListWorktrees()
-	if err != nil {
-		result.addErr("listing worktrees", err)
-	} else {
-		result.WorktreesTotal = len(worktrees)
-		if len(worktrees) > 0 {
-			uiStopProgress()
-			uiSection(fmt.Sprintf("Worktrees (%d)", len(worktrees)))
-
-			for _, wt := range worktrees {
-				_, branchExists := branchSet[wt.Branch]
-
-				if branchExists {
-					uiItem(fmt.Sprintf("%s (branch: %s)", wt.Path, wt.Branch))
-				} else {
-					uiItem(wt.Path)
-				}
-
-				pr, hasPR := prs[wt.Branch]
-				if hasPR {
-					uiPR(pr)
-				}
-
-				var confirmed bool
-				if auto {
-					confirmed = isMerged(prs, wt.Branch)
-				} else {
-					title := "Remove worktree?"
-					if branchExists {
-						title = "Remove worktree and delete branch?"
-					}
-
-					defaultVal := hasPR && pr.State != "OPEN"
-					var err error
-					confirmed, err = confirm(title, defaultVal)
-					if errors.Is(err, ErrUserAborted) {
-						return result
-					} else if err != nil {
-						result.addErr("prompting for worktree removal", err)
-						continue
-					}
-				}
-
-				if confirmed {
-					if err := gitRemoveWorktree(wt.Path); err != nil {
-						result.addErr("removing worktree "+wt.Path, err)
-					} else {
-						uiOK("Removed worktree")
-						result.WorktreesRemoved++
-					}
-
-					if branchExists {
-						if err := gitDeleteBranch(wt.Branch); err != nil {
-							result.addErr("deleting branch "+wt.Branch, err)
-						} else {
-							uiOK("Deleted branch " + wt.Branch)
-							deletedBranches[wt.Branch] = struct{}{}
-							result.BranchesDeleted++
-						}
-					}
-				} else {
-					uiSkipped()
-					result.WorktreesSkipped++
-					if branchExists {
-						skippedBranches[wt.Branch] = struct{}{}
-					}
-				}
-				fmt.Println()
-			}
-		} else {
-			uiDim("No worktrees to clean up")
+func clean(ctx context.Context, dir string, showBrand bool, opts cliOptions) engine.Result {
+	var ui engine.Observer
+	switch {
+	case !opts.showUI():
+	case opts.plain:
+		ui = plainObserver{}
+	default:
+		if showBrand {
+			uiBrand()
 		}
+		ui = &cliObserver{}
 	}
 
-	// Filter out branches already handled during worktree cleanup
-	var remainingBranches []string
-	for _, b := range branches {
-		if _, deleted := deletedBranches[b]; deleted {
-			continue
-		}
-		if _, skipped := skippedBranches[b]; skipped {
-			continue
-		}
-		remainingBranches = append(remainingBranches, b)
+	c := &engine.Cleaner{
+		Decide:   decide,
+		Observer: engine.Observers(ui, opts.events),
 	}
-
-	result.BranchesTotal = len(remainingBranches)
-	if len(remainingBranches) > 0 {
-		uiStopProgress()
-		uiSection(fmt.Sprintf("Branches (%d)", len(remainingBranches)))
-
-		for _, branch := range remainingBranches {
-			uiItem(branch)
-
-			pr, hasPR := prs[branch]
-			if hasPR {
-				uiPR(pr)
-			}
-
-			var confirmed bool
-			if auto {
-				confirmed = isMerged(prs, branch)
-			} else {
-				defaultVal := hasPR && pr.State != "OPEN"
-				var err error
-				confirmed, err = confirm("Delete branch?", defaultVal)
-				if errors.Is(err, ErrUserAborted) {
-					return result
-				} else if err != nil {
-					result.addErr("prompting for branch deletion", err)
-					continue
-				}
-			}
-
-			if confirmed {
-				if err := gitDeleteBranch(branch); err != nil {
-					result.addErr("deleting branch "+branch, err)
-				} else {
-					uiOK("Deleted")
-					result.BranchesDeleted++
-				}
-			} else {
-				uiSkipped()
-				result.BranchesSkipped++
-			}
-			fmt.Println()
-		}
-	} else {
-		uiDim("No branches to clean up")
+	if opts.checklist {
+		c.Select = selectPrompts
 	}
+	return runRepo(ctx, c, dir, opts)
+}
 
-	uiStopProgress()
-	uiDone()
+// runRepo runs c on the repo at repoPath with the options its config
+// layers describe. A config that cannot be loaded is reported as an error
+// for the repo, like a failed step, and the repo is left untouched.
+func runRepo(ctx context.Context, c *engine.Cleaner, repoPath string, opts cliOptions) engine.Result {
+	engineOpts, err := opts.engineOptions(repoPath)
+	if err == nil {
+		return c.Run(ctx, repoPath, engineOpts)
+	}
 
+	absDir, _ := filepath.Abs(repoPath)
+	result := engine.Result{
+		Name:             filepath.Base(absDir),
+		Path:             absDir,
+		RemovedWorktrees: []engine.RemovedWorktree{},
+		DeletedBranches:  []engine.DeletedBranch{},
+		Errors:           []engine.StepError{{Step: "config", Message: err.Error()}},
+	}
+	if c.Observer != nil {
+		now := time.Now()
+		c.Observer.Observe(engine.Event{Type: engine.EventError, Time: now, Repo: absDir, Step: "config", Message: err.Error()})
+		c.Observer.Observe(engine.Event{Type: engine.EventRepoFinished, Time: now, Repo: absDir, Result: &result})
+	}
 	return result
 }
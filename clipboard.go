@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+type clipboardCopiedMsg struct {
+	text string
+	err  error
+}
+
+// copyToClipboard copies text with the platform's clipboard command. Like
+// openURL, it tries each candidate in turn and the first that succeeds wins.
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"})
+	}
+
+	lastErr := errors.New("no clipboard command found")
+	for _, c := range candidates {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		cmd := exec.CommandContext(ctx, c[0], c[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.WaitDelay = time.Second
+		err := cmd.Run()
+		cancel()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("copying to clipboard: %w", lastErr)
+}
+
+// copyCmd copies text in the background and reports the outcome.
+func copyCmd(text string) tea.Cmd {
+	return func() tea.Msg {
+		return clipboardCopiedMsg{text: text, err: copyToClipboard(text)}
+	}
+}
@@ -3,18 +3,44 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	tea "charm.land/bubbletea/v2"
+	"github.com/kpurdon/tidygit/engine"
 )
 
 // ErrUserAborted is returned when the user presses Ctrl+C during a prompt.
 var ErrUserAborted = errors.New("user aborted")
 
+// ErrNoTerminal is returned when a prompt is needed but there is no terminal
+// to show it on.
+var ErrNoTerminal = errors.New("cannot prompt: not running in a terminal")
+
+// promptOutput is where interactive prompts are drawn. It is switched to
+// stderr when stdout carries machine-readable output.
+var promptOutput io.Writer = os.Stdout
+
 type confirmModel struct {
-	title    string
-	value    bool
-	done     bool
-	aborted  bool
+	prompt  engine.Prompt
+	value   bool
+	done    bool
+	aborted bool
+	// answer is the final answer once done is set.
+	answer engine.Answer
+
+	// Branch inspector state, toggled with "i".
+	showDetails bool
+	details     *engine.BranchDetails
+	detailsErr  error
+
+	// status is a one-line note about the last open/copy action.
+	status string
+}
+
+func newConfirmModel(p engine.Prompt) confirmModel {
+	return confirmModel{prompt: p, value: p.Default}
 }
 
 func (m confirmModel) Init() tea.Cmd {
@@ -23,19 +49,78 @@ func (m confirmModel) Init() tea.Cmd {
 
 func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case branchDetailsMsg:
+		m.details, m.detailsErr = &msg.details, msg.err
+	case browserOpenedMsg:
+		if msg.err != nil {
+			m.status = errStyle.Render(msg.err.Error())
+		} else {
+			m.status = okStyle.Render("Opened PR in browser")
+		}
+	case clipboardCopiedMsg:
+		if msg.err == nil {
+			m.status = okStyle.Render("Copied PR URL to clipboard")
+			break
+		}
+		// Fall back to asking the terminal, which many terminals and tmux
+		// setups silently ignore, so the note cannot claim it worked.
+		m.status = warnStyle.Render("No clipboard command worked; sent PR URL to the terminal clipboard (OSC 52)")
+		return m, tea.SetClipboard(msg.text)
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "y", "Y":
 			m.value = true
-			m.done = true
-			return m, tea.Quit
+			return m.finish(engine.AnswerYes)
 		case "n", "N":
 			m.value = false
-			m.done = true
-			return m, tea.Quit
+			return m.finish(engine.AnswerNo)
 		case "enter":
-			m.done = true
-			return m, tea.Quit
+			if m.value {
+				return m.finish(engine.AnswerYes)
+			}
+			return m.finish(engine.AnswerNo)
+		case "a":
+			m.value = true
+			return m.finish(engine.AnswerYesToAll)
+		case "d":
+			m.value = false
+			return m.finish(engine.AnswerNoToAll)
+		case "s":
+			return m.finish(engine.AnswerSkipRepo)
+		case "q":
+			return m.finish(engine.AnswerQuit)
+		case "e":
+			if m.prompt.Kind == engine.PromptGroup {
+				return m.finish(engine.AnswerEach)
+			}
+		case "k":
+			if m.canRemember() {
+				m.value = false
+				return m.finish(engine.AnswerKeep)
+			}
+		case "z":
+			if m.canRemember() {
+				m.value = false
+				return m.finish(engine.AnswerSnooze)
+			}
+		case "i":
+			if m.prompt.Branch == "" && m.prompt.Kind != engine.PromptGroup {
+				break
+			}
+			m.showDetails = !m.showDetails
+			if m.showDetails && m.prompt.Branch != "" && m.details == nil && m.detailsErr == nil {
+				return m, inspectCmd(m.prompt)
+			}
+		case "o":
+			if m.prompt.PR != nil {
+				m.status = dimStyle.Render("Opening " + m.prompt.PR.URL + "...")
+				return m, openURLCmd(m.prompt.PR.URL)
+			}
+		case "c":
+			if m.prompt.PR != nil {
+				m.status = dimStyle.Render("Copying " + m.prompt.PR.URL + "...")
+				return m, copyCmd(m.prompt.PR.URL)
+			}
 		case "ctrl+c":
 			m.aborted = true
 			return m, tea.Quit
@@ -48,9 +133,28 @@ func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// canRemember reports whether a keep decision can be remembered for the
+// prompt, which needs a branch offered for deletion to store it on.
+func (m confirmModel) canRemember() bool {
+	if m.prompt.Kind == engine.PromptRenameDefault {
+		return false
+	}
+	return m.prompt.Branch != "" || m.prompt.Kind == engine.PromptGroup
+}
+
+func (m confirmModel) finish(answer engine.Answer) (tea.Model, tea.Cmd) {
+	m.answer = answer
+	m.done = true
+	return m, tea.Quit
+}
+
 func (m confirmModel) View() tea.View {
-	yes := "Yes"
-	no := "No"
+	return tea.NewView(m.render() + "\n")
+}
+
+// render returns the prompt and its key hints without a trailing newline.
+func (m confirmModel) render() string {
+	var yes, no string
 	if m.value {
 		yes = okStyle.Render("▸ Yes")
 		no = dimStyle.Render("  No")
@@ -59,27 +163,70 @@ func (m confirmModel) View() tea.View {
 		no = errStyle.Render("▸ No")
 	}
 
-	return tea.NewView(fmt.Sprintf("  %s %s / %s\n", warnStyle.Render("?")+" "+m.title, yes, no))
+	hint := "    y/n · a yes to all · d no to all · s skip repo · q quit"
+	switch {
+	case m.prompt.Kind == engine.PromptGroup:
+		hint += " · e decide each · i list branches"
+	case m.prompt.Branch != "":
+		hint += " · i inspect"
+	}
+	if m.canRemember() {
+		hint += " · k keep until changed · z snooze"
+	}
+	if m.prompt.PR != nil {
+		hint += " · o open PR · c copy URL"
+	}
+
+	title := m.prompt.Title
+	if !m.prompt.LastCommit.IsZero() {
+		age := "last commit " + formatAge(m.prompt.LastCommit) + " ago"
+		if m.prompt.Class.Stale {
+			title += " " + warnStyle.Render("("+age+", stale)")
+		} else {
+			title += " " + dimStyle.Render("("+age+")")
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("  %s %s / %s", warnStyle.Render("?")+" "+title, yes, no),
+		dimStyle.Render(hint),
+	}
+	if m.status != "" {
+		lines = append(lines, "    "+m.status)
+	}
+	if m.showDetails {
+		switch {
+		case m.prompt.Kind == engine.PromptGroup:
+			lines = append(lines, groupLines(m.prompt.Members)...)
+		case m.detailsErr != nil:
+			lines = append(lines, errLine(m.detailsErr.Error()))
+		case m.details == nil:
+			lines = append(lines, dimLine("  Inspecting "+m.prompt.Branch+"..."))
+		default:
+			lines = append(lines, detailsLines(*m.details)...)
+		}
+	}
+	return strings.Join(lines, "\n")
 }
 
-// confirm shows an interactive yes/no prompt and returns the user's choice.
-// It returns ErrUserAborted if the user presses Ctrl+C.
-func confirm(title string, defaultValue bool) (bool, error) {
-	m := confirmModel{
-		title: title,
-		value: defaultValue,
+// confirm shows an interactive yes/no prompt and returns the user's answer.
+// It returns ErrUserAborted if the user presses Ctrl+C and ErrNoTerminal if
+// stdin or the prompt output is not a terminal.
+func confirm(prompt engine.Prompt) (engine.Answer, error) {
+	if !canPrompt(promptOutput) {
+		return engine.AnswerNo, ErrNoTerminal
 	}
 
-	p := tea.NewProgram(m)
+	p := tea.NewProgram(newConfirmModel(prompt), tea.WithOutput(promptOutput))
 	result, err := p.Run()
 	if err != nil {
-		return false, err
+		return engine.AnswerNo, err
 	}
 
 	final := result.(confirmModel)
 	if final.aborted {
-		return false, ErrUserAborted
+		return engine.AnswerQuit, ErrUserAborted
 	}
 
-	return final.value, nil
+	return final.answer, nil
 }
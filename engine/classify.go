@@ -0,0 +1,483 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the classification of a branch, from which its prompt default
+// and auto-mode decision follow.
+type Status string
+
+const (
+	// StatusProtected branches are never offered for deletion.
+	StatusProtected Status = "protected"
+	// StatusUnpushed branches have commits that exist on no remote.
+	StatusUnpushed Status = "unpushed-work"
+	// StatusDirty worktrees have uncommitted changes or untracked files.
+	StatusDirty Status = "uncommitted-changes"
+	// StatusOpenPR branches have an open pull request.
+	StatusOpenPR Status = "open-pr"
+	// StatusMergedPR branches have a merged pull request.
+	StatusMergedPR Status = "merged-via-pr"
+	// StatusNew branches have not moved since they were created, so being
+	// contained in the default branch only means no work was done yet.
+	StatusNew Status = "new"
+	// StatusAncestor branches are fully contained in the default branch.
+	StatusAncestor Status = "ancestor-of-default"
+	// StatusSquashMerged branches have had their combined changes applied
+	// to the default branch as a single commit.
+	StatusSquashMerged Status = "squash-merged"
+	// StatusUpstreamGone branches track a remote branch that was deleted.
+	StatusUpstreamGone Status = "upstream-gone"
+	// StatusUnknown branches match none of the above.
+	StatusUnknown Status = "unknown"
+)
+
+// Deletable reports whether a branch with this status is safe to delete:
+// its work is merged or otherwise preserved on a remote.
+func (s Status) Deletable() bool {
+	switch s {
+	case StatusMergedPR, StatusAncestor, StatusSquashMerged, StatusUpstreamGone:
+		return true
+	}
+	return false
+}
+
+// Classification is the Status of a branch and the facts that led to it.
+type Classification struct {
+	Status Status `json:"status"`
+	// Reasons are human-readable facts about the branch, the one that
+	// decided Status first.
+	Reasons []string `json:"reasons"`
+	// Stale reports whether the branch has seen no activity for longer
+	// than Options.OlderThan.
+	Stale bool `json:"stale,omitempty"`
+	// Kept describes how long a remembered decision to keep the branch
+	// lasts, e.g. "until 2026-11-01". Empty if there is none.
+	Kept string `json:"kept,omitempty"`
+}
+
+// Offered reports whether the branch may be offered for deletion at all.
+// Protected branches and branches the user chose to keep are not.
+func (c Classification) Offered() bool {
+	return c.Status != StatusProtected && c.Kept == ""
+}
+
+// classifier classifies the branches of one repo. It loads the repo-wide
+// facts once so classifying each branch only costs per-branch lookups.
+type classifier struct {
+	dir           string
+	defaultBranch string
+	// base is the remote-tracking default branch, e.g. origin/main, or ""
+	// if the default branch is unknown.
+	base string
+	prs  map[string]PR
+
+	// olderThan is the age after which a branch is stale, or 0 to never
+	// mark branches stale.
+	olderThan time.Duration
+	protect   Protection
+
+	// ancestors are the branches contained in base.
+	ancestors map[string]bool
+	upstreams map[string]upstreamInfo
+	// dates are the commit dates of the branch tips.
+	dates map[string]time.Time
+	keeps map[string]keep
+}
+
+type upstreamInfo struct {
+	name string
+	gone bool
+}
+
+// newClassifier loads what classification needs from the repo at dir.
+// Facts that cannot be loaded are left out rather than failing, which makes
+// the affected branches classify more conservatively.
+func newClassifier(ctx context.Context, dir, defaultBranch string, prs map[string]PR, opts Options) *classifier {
+	c := &classifier{
+		dir:           dir,
+		defaultBranch: defaultBranch,
+		prs:           prs,
+		olderThan:     opts.OlderThan,
+		protect:       opts.Protect,
+	}
+	if defaultBranch != "" {
+		c.base = opts.Remote + "/" + defaultBranch
+		c.ancestors, _ = gitMergedBranches(ctx, dir, c.base)
+	}
+	c.upstreams, _ = gitUpstreams(ctx, dir)
+	c.dates, _ = gitBranchCommitDates(ctx, dir)
+	c.keeps, _ = gitKeeps(ctx, dir)
+	return c
+}
+
+// classify returns the Classification of branch. Statuses are checked from
+// most to least cautious, so a branch with work that exists nowhere else is
+// never reported as merged.
+func (c *classifier) classify(ctx context.Context, branch string) Classification {
+	var cl Classification
+	decide := func(s Status, reason string) {
+		if cl.Status == "" {
+			cl.Status = s
+			cl.Reasons = append([]string{reason}, cl.Reasons...)
+			return
+		}
+		cl.Reasons = append(cl.Reasons, reason)
+	}
+	note := func(reason string) {
+		cl.Reasons = append(cl.Reasons, reason)
+	}
+
+	if branch == c.defaultBranch {
+		decide(StatusProtected, "is the default branch")
+	}
+	if pattern, ok := c.protect.Branch(branch); ok {
+		decide(StatusProtected, "matches protected pattern "+pattern)
+	}
+
+	pr, hasPR := c.prs[branch]
+	if hasPR && pr.State == "OPEN" {
+		decide(StatusOpenPR, fmt.Sprintf("PR #%d is open", pr.Number))
+	}
+
+	// Commits that were part of a PR's head were pushed at some point,
+	// even if the remote branch has since been deleted.
+	unpushed, err := gitUnpushedCount(ctx, c.dir, branch, pr.HeadSHA)
+	countFailed := err != nil
+
+	ancestor := c.ancestors[branch]
+	squashed := false
+	// Squash detection writes a dangling commit, so only run it when it
+	// can change the outcome.
+	if c.base != "" && !ancestor && cl.Status == "" && (unpushed > 0 || countFailed || pr.State != "MERGED") {
+		squashed, _ = gitSquashMerged(ctx, c.dir, c.base, branch)
+	}
+
+	// A branch whose commits could not be counted may have unpushed work,
+	// unless its changes are in the default branch.
+	if countFailed {
+		if ancestor || squashed {
+			note("could not count unpushed commits, but the changes are in " + c.base)
+		} else {
+			decide(StatusUnpushed, "could not count unpushed commits, so it may have some")
+		}
+	}
+	if unpushed > 0 {
+		if ancestor || squashed {
+			note(fmt.Sprintf("%d commit(s) not on any remote, but their changes are in %s", unpushed, c.base))
+		} else {
+			decide(StatusUnpushed, fmt.Sprintf("%d commit(s) not pushed to any remote", unpushed))
+		}
+	}
+
+	switch {
+	case !hasPR:
+		note("no PR found")
+	case pr.State == "MERGED":
+		decide(StatusMergedPR, fmt.Sprintf("PR #%d is merged", pr.Number))
+	case pr.State == "CLOSED":
+		note(fmt.Sprintf("PR #%d was closed without merging", pr.Number))
+	}
+
+	// A branch created from the default branch is contained in it until
+	// its first commit, so only one that has moved counts as merged. One
+	// with an upstream may have been fast-forwarded there and merged.
+	lastWhat, last := "last commit", c.dates[branch]
+	if _, hasUpstream := c.upstreams[branch]; ancestor && !hasPR && !hasUpstream {
+		if created, ok := gitBranchUntouched(ctx, c.dir, branch); ok {
+			decide(StatusNew, "has no commits of its own since it was created")
+			lastWhat, last = "created", created
+		}
+	}
+	if ancestor {
+		decide(StatusAncestor, "tip is contained in "+c.base)
+	}
+	if squashed {
+		decide(StatusSquashMerged, "changes are already in "+c.base+" as a squashed commit")
+	}
+
+	if up, ok := c.upstreams[branch]; !ok {
+		note("has no upstream")
+	} else if up.gone {
+		decide(StatusUpstreamGone, "upstream "+up.name+" no longer exists")
+	}
+
+	if cl.Status == "" {
+		cl.Status = StatusUnknown
+		cl.Reasons = append([]string{"nothing shows the work is merged"}, cl.Reasons...)
+	}
+	c.markStale(&cl, lastWhat, last)
+
+	if k, ok := c.keeps[branch]; ok {
+		sha, err := gitBranchSHA(ctx, c.dir, branch)
+		if err == nil && k.active(sha) {
+			cl.Kept = k.describe()
+			note("remembered decision to keep it " + cl.Kept)
+		}
+	}
+	return cl
+}
+
+// markStale sets cl.Stale if the last activity, described by what, happened
+// longer than olderThan ago.
+func (c *classifier) markStale(cl *Classification, what string, last time.Time) {
+	if c.olderThan <= 0 || last.IsZero() {
+		return
+	}
+	age := time.Since(last)
+	if age < c.olderThan {
+		return
+	}
+	cl.Stale = true
+	cl.Reasons = append(cl.Reasons, fmt.Sprintf("%s %s ago, older than %s",
+		what, FormatAge(age.Truncate(24*time.Hour)), FormatAge(c.olderThan)))
+}
+
+// protectWorktree marks cl protected if the worktree at path matches a
+// protected pattern.
+func (c *classifier) protectWorktree(cl *Classification, path string) {
+	pattern, ok := c.protect.Worktree(path)
+	if !ok {
+		return
+	}
+	c.override(cl, StatusProtected, "worktree matches protected pattern "+pattern)
+}
+
+// classifyWorktree refines cl, the classification of branch, with the state
+// of its worktree at path, and returns when the worktree was last worked in.
+func (c *classifier) classifyWorktree(ctx context.Context, cl *Classification, branch, path string) time.Time {
+	if branch == "" {
+		c.classifyDetached(ctx, cl, path)
+	}
+	changes, err := gitWorktreeChanges(ctx, path)
+	modified := worktreeModified(ctx, path, changes)
+	if !modified.IsZero() {
+		c.worktreeActivity(cl, branch, modified)
+	}
+	c.markDirty(cl, changes, err)
+	return modified
+}
+
+// classifyDetached marks cl, the classification of a worktree with no
+// branch, as unpushed unless every commit of its HEAD is on a remote. Its
+// commits are only reachable from the worktree, so removing it would lose
+// them.
+func (c *classifier) classifyDetached(ctx context.Context, cl *Classification, path string) {
+	unpushed, err := gitHeadUnpushedCount(ctx, path)
+	switch {
+	case err != nil:
+		c.override(cl, StatusUnpushed, "could not count unpushed commits, so it may have some")
+	case unpushed > 0:
+		c.override(cl, StatusUnpushed, fmt.Sprintf("%d commit(s) not pushed to any remote", unpushed))
+	default:
+		cl.Reasons = append(cl.Reasons, "HEAD is on a remote")
+	}
+}
+
+// worktreeModified returns when the worktree at path was last worked in:
+// the newest of its index, which every stage, commit and checkout writes,
+// and its changed files.
+func worktreeModified(ctx context.Context, path string, changes []string) time.Time {
+	files := make([]string, 0, len(changes)+1)
+	if out, err := gitCmd(ctx, path, "rev-parse", "--path-format=absolute", "--git-path", "index").Output(); err == nil {
+		files = append(files, strings.TrimSpace(string(out)))
+	}
+	for _, change := range changes {
+		files = append(files, filepath.Join(path, change))
+	}
+
+	var latest time.Time
+	for _, f := range files {
+		if info, err := os.Lstat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// markDirty marks cl dirty if its worktree has uncommitted changes or
+// untracked files, or if checking for them failed with err, since removing
+// the worktree would lose them.
+func (c *classifier) markDirty(cl *Classification, changes []string, err error) {
+	var reason string
+	switch {
+	case err != nil:
+		reason = "could not check the worktree for uncommitted changes"
+	case len(changes) > 0:
+		reason = fmt.Sprintf("worktree has %d uncommitted change(s)", len(changes))
+	default:
+		return
+	}
+	c.override(cl, StatusDirty, reason)
+}
+
+// override sets the status of cl to s with reason first, unless cl is
+// protected, which only gains the reason.
+func (c *classifier) override(cl *Classification, s Status, reason string) {
+	if cl.Status == StatusProtected {
+		cl.Reasons = append(cl.Reasons, reason)
+		return
+	}
+	cl.Status = s
+	cl.Reasons = append([]string{reason}, cl.Reasons...)
+}
+
+// worktreeActivity refines the staleness of a worktree's classification
+// with when it was last worked in, so a worktree that is still being worked
+// in is not stale just because its branch has no new commits.
+func (c *classifier) worktreeActivity(cl *Classification, branch string, modified time.Time) {
+	if c.olderThan <= 0 {
+		return
+	}
+	if _, ok := c.dates[branch]; !ok {
+		c.markStale(cl, "worktree last worked in", modified)
+		return
+	}
+	if cl.Stale && time.Since(modified) < c.olderThan {
+		cl.Stale = false
+		cl.Reasons = append(cl.Reasons, fmt.Sprintf("but the worktree was worked in %s ago",
+			FormatAge(time.Since(modified).Truncate(time.Minute))))
+	}
+}
+
+// ErrNoBranch is returned by ClassifyBranch for a branch that does not
+// exist.
+var ErrNoBranch = errors.New("no such branch")
+
+// ClassifyBranch explains how the branch in the repo at repoPath would be
+// classified by a cleanup run, and returns its PR if one was found.
+// Only the OlderThan, Protect, Remote and Offline options are used. It does
+// not fetch, so the result reflects the remote-tracking refs as they are.
+func ClassifyBranch(ctx context.Context, repoPath, branch string, opts Options) (Classification, *PR, error) {
+	opts = opts.withDefaults()
+	if _, err := gitBranchSHA(ctx, repoPath, branch); err != nil {
+		return Classification{}, nil, fmt.Errorf("%s: %w", branch, ErrNoBranch)
+	}
+
+	// Both lookups only narrow the classification, so failures fall back
+	// to classifying without them.
+	defaultBranch, _, _ := opts.defaultBranch(ctx, repoPath)
+	var prs map[string]PR
+	if opts.Offline {
+		prs, _, _ = loadPRCache(ctx, repoPath)
+	} else {
+		prs, _ = ghFetchPRs(ctx, repoPath)
+	}
+
+	return newClassifier(ctx, repoPath, defaultBranch, prs, opts).classify(ctx, branch), prFor(prs, branch), nil
+}
+
+// gitMergedBranches returns the local branches whose tips are contained in
+// base.
+func gitMergedBranches(ctx context.Context, dir, base string) (map[string]bool, error) {
+	out, err := gitCmd(ctx, dir, "branch", "--format=%(refname:short)", "--merged", base).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("listing branches merged into %s: %s: %w", base, strings.TrimSpace(string(out)), err)
+	}
+
+	merged := make(map[string]bool)
+	for _, b := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if b != "" {
+			merged[b] = true
+		}
+	}
+	return merged, nil
+}
+
+// gitUpstreams returns the upstream of every local branch that has one.
+func gitUpstreams(ctx context.Context, dir string) (map[string]upstreamInfo, error) {
+	out, err := gitCmd(ctx, dir, "for-each-ref", "--format=%(refname:short)%00%(upstream:short)%00%(upstream:track)", "refs/heads").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("reading upstreams: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	upstreams := make(map[string]upstreamInfo)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 || fields[1] == "" {
+			continue
+		}
+		upstreams[fields[0]] = upstreamInfo{name: fields[1], gone: fields[2] == "[gone]"}
+	}
+	return upstreams, nil
+}
+
+// gitBranchUntouched reports whether the reflog of branch shows it was
+// created and has not moved since, and returns when it was created. Without
+// a reflog it reports false.
+func gitBranchUntouched(ctx context.Context, dir, branch string) (time.Time, bool) {
+	out, err := gitCmd(ctx, dir, "reflog", "show", "--format=%gs%x00%ct", "refs/heads/"+branch, "--").Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	entries := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(entries) != 1 {
+		return time.Time{}, false
+	}
+	subject, unix, _ := strings.Cut(entries[0], "\x00")
+	if !strings.HasPrefix(subject, "branch: Created from") {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// gitUnpushedCount returns the number of commits on branch that are on no
+// remote-tracking branch. Commits reachable from pushed, if set and present
+// locally, also count as pushed.
+func gitUnpushedCount(ctx context.Context, dir, branch, pushed string) (int, error) {
+	args := []string{"rev-list", "--count", "refs/heads/" + branch, "--not", "--remotes"}
+	if pushed != "" && gitCmd(ctx, dir, "cat-file", "-e", pushed+"^{commit}").Run() == nil {
+		args = append(args, pushed)
+	}
+	out, err := gitCmd(ctx, dir, args...).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("counting unpushed commits on %s: %s: %w", branch, strings.TrimSpace(string(out)), err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// gitHeadUnpushedCount returns the number of commits on HEAD of the worktree
+// at path that are on no remote-tracking branch.
+func gitHeadUnpushedCount(ctx context.Context, path string) (int, error) {
+	out, err := gitCmd(ctx, path, "rev-list", "--count", "HEAD", "--not", "--remotes").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("counting unpushed commits in %s: %s: %w", path, strings.TrimSpace(string(out)), err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// gitSquashMerged reports whether the combined changes of branch since it
+// forked from base already exist in base as a single commit. It squashes
+// the branch into a temporary dangling commit and asks git cherry whether
+// an equivalent patch is in base.
+func gitSquashMerged(ctx context.Context, dir, base, branch string) (bool, error) {
+	out, err := gitCmd(ctx, dir, "merge-base", base, "refs/heads/"+branch).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("finding merge base of %s: %s: %w", branch, strings.TrimSpace(string(out)), err)
+	}
+	mergeBase := strings.TrimSpace(string(out))
+
+	out, err = gitCmd(ctx, dir, "commit-tree", "refs/heads/"+branch+"^{tree}", "-p", mergeBase, "-m", "tidygit squash check").CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("squashing %s: %s: %w", branch, strings.TrimSpace(string(out)), err)
+	}
+	squashed := strings.TrimSpace(string(out))
+
+	out, err = gitCmd(ctx, dir, "cherry", base, squashed).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("comparing %s with %s: %s: %w", branch, base, strings.TrimSpace(string(out)), err)
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(out)), "-"), nil
+}
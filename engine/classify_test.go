@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRepo creates a clone of a fresh bare repo with one pushed commit on
+// main and returns the path of the clone.
+func testRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for k, v := range map[string]string{
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_AUTHOR_NAME":     "test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+	} {
+		t.Setenv(k, v)
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "clone")
+	git(t, root, "init", "-q", "--bare", "-b", "main", "origin.git")
+	git(t, root, "clone", "-q", "origin.git", "clone")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "init")
+	git(t, dir, "push", "-q", "-u", "origin", "main")
+	git(t, dir, "remote", "set-head", "origin", "main")
+	return dir
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %s: %v", strings.Join(args, " "), out, err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	dir := testRepo(t)
+
+	// merged had a commit of its own, which is now in main.
+	git(t, dir, "switch", "-q", "-c", "merged")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "merged work")
+	git(t, dir, "switch", "-q", "main")
+	git(t, dir, "merge", "-q", "--ff-only", "merged")
+	git(t, dir, "push", "-q", "origin", "main")
+	git(t, dir, "fetch", "-q")
+
+	// fresh was just created and has no commits of its own.
+	git(t, dir, "branch", "fresh")
+
+	// work has a commit that exists nowhere else.
+	git(t, dir, "switch", "-q", "-c", "work")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "local work")
+	git(t, dir, "switch", "-q", "main")
+
+	// gone tracked a remote branch that was deleted, while its commit
+	// survives on another one.
+	git(t, dir, "switch", "-q", "-c", "gone")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "gone work")
+	git(t, dir, "push", "-q", "-u", "origin", "gone")
+	git(t, dir, "push", "-q", "origin", "gone:other")
+	git(t, dir, "switch", "-q", "main")
+	git(t, dir, "push", "-q", "origin", "--delete", "gone")
+	git(t, dir, "fetch", "-q", "--prune")
+
+	// pr-merged has a pushed commit and a merged PR.
+	git(t, dir, "switch", "-q", "-c", "pr-merged")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "pr work")
+	git(t, dir, "push", "-q", "origin", "pr-merged")
+	git(t, dir, "switch", "-q", "main")
+
+	ctx := context.Background()
+	opts := Options{}.withDefaults()
+	opts.Protect, _ = NewProtection([]string{"release/*"})
+	git(t, dir, "branch", "release/1")
+	c := newClassifier(ctx, dir, "main", map[string]PR{
+		"pr-merged": {Number: 1, Branch: "pr-merged", State: "MERGED"},
+	}, opts)
+
+	tests := []struct {
+		branch string
+		want   Status
+	}{
+		{"main", StatusProtected},
+		{"release/1", StatusProtected},
+		{"merged", StatusAncestor},
+		{"fresh", StatusNew},
+		{"work", StatusUnpushed},
+		{"gone", StatusUpstreamGone},
+		{"pr-merged", StatusMergedPR},
+	}
+	for _, tt := range tests {
+		if got := c.classify(ctx, tt.branch); got.Status != tt.want {
+			t.Errorf("classify(%q) = %s %v, want %s", tt.branch, got.Status, got.Reasons, tt.want)
+		}
+	}
+}
+
+func TestClassifyWorktree(t *testing.T) {
+	dir := testRepo(t)
+	wt := filepath.Join(filepath.Dir(dir), "wt")
+	git(t, dir, "worktree", "add", "-q", "-b", "newfeat", wt)
+
+	ctx := context.Background()
+	opts := Options{}.withDefaults()
+	policy := AutoPolicy{MergedLocally: true, UpstreamGone: true, Stale: true}
+	c := newClassifier(ctx, dir, "main", nil, opts)
+
+	cl := c.classify(ctx, "newfeat")
+	c.classifyWorktree(ctx, &cl, "newfeat", wt)
+	if cl.Status != StatusNew || policy.Deletes(cl, nil) {
+		t.Errorf("new worktree classified %s %v, deleted %v; want %s, kept", cl.Status, cl.Reasons, policy.Deletes(cl, nil), StatusNew)
+	}
+
+	if err := os.WriteFile(filepath.Join(wt, "notes.txt"), []byte("wip\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cl = c.classify(ctx, "newfeat")
+	modified := c.classifyWorktree(ctx, &cl, "newfeat", wt)
+	if cl.Status != StatusDirty || policy.Deletes(cl, nil) {
+		t.Errorf("dirty worktree classified %s %v, deleted %v; want %s, kept", cl.Status, cl.Reasons, policy.Deletes(cl, nil), StatusDirty)
+	}
+	if modified.IsZero() {
+		t.Error("dirty worktree has no activity time")
+	}
+}
+
+func TestWorktreeChanges(t *testing.T) {
+	dir := testRepo(t)
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, dir, "add", "a.txt")
+	git(t, dir, "commit", "-q", "-m", "a")
+	git(t, dir, "mv", "a.txt", "renamed.txt")
+
+	changes, err := gitWorktreeChanges(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"renamed.txt", "sub/b.txt"}
+	if strings.Join(changes, ",") != strings.Join(want, ",") {
+		t.Errorf("gitWorktreeChanges() = %q, want %q", changes, want)
+	}
+}
+
+func TestClassifyDetachedWorktree(t *testing.T) {
+	dir := testRepo(t)
+	pushed := filepath.Join(filepath.Dir(dir), "pushed")
+	local := filepath.Join(filepath.Dir(dir), "local")
+	git(t, dir, "worktree", "add", "-q", "--detach", pushed)
+	git(t, dir, "worktree", "add", "-q", "--detach", local)
+	git(t, local, "commit", "-q", "--allow-empty", "-m", "detached work")
+
+	// Both worktrees were last worked in long ago.
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	for _, wt := range []string{pushed, local} {
+		index := filepath.Join(dir, ".git", "worktrees", filepath.Base(wt), "index")
+		if err := os.Chtimes(index, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	opts := Options{OlderThan: 90 * 24 * time.Hour}.withDefaults()
+	policy := AutoPolicy{Stale: true}
+	c := newClassifier(ctx, dir, "main", nil, opts)
+
+	tests := []struct {
+		path    string
+		want    Status
+		deleted bool
+	}{
+		{pushed, StatusUnknown, true},
+		{local, StatusUnpushed, false},
+	}
+	for _, tt := range tests {
+		cl := Classification{Status: StatusUnknown}
+		c.classifyWorktree(ctx, &cl, "", tt.path)
+		if cl.Status != tt.want || !cl.Stale || policy.Deletes(cl, nil) != tt.deleted {
+			t.Errorf("detached worktree %s classified %s %v, stale %v; want %s, stale, deleted %v",
+				filepath.Base(tt.path), cl.Status, cl.Reasons, cl.Stale, tt.want, tt.deleted)
+		}
+	}
+}
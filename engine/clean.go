@@ -0,0 +1,787 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// run holds the state of a single Cleaner.Run invocation.
+type run struct {
+	dir    string
+	opts   Options
+	decide DecideFunc
+	sel    SelectFunc
+	obs    Observer
+	result Result
+
+	// all is the sticky answer set by AnswerYesToAll or AnswerNoToAll.
+	all *bool
+
+	// State shared between pipeline steps.
+	defaultBranch   string
+	onDefaultBranch bool
+	prs             map[string]PR
+	plan            *plan
+	// setUp is set once the before_repo hooks have run, so after_repo
+	// only tears down a repo that was set up.
+	setUp bool
+}
+
+func (r *run) emit(e Event) {
+	if r.obs == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Repo = r.dir
+	r.obs.Observe(e)
+}
+
+func (r *run) addErr(step, msg string, err error) {
+	e := StepError{Step: step, Message: fmt.Sprintf("%s: %v", msg, err)}
+	r.result.Errors = append(r.result.Errors, e)
+	r.emit(Event{Type: EventError, Step: step, Message: e.Message})
+}
+
+// skip records that the item described by e was left in place.
+func (r *run) skip(e Event) {
+	e.Type = EventSkipped
+	r.emit(e)
+}
+
+// step runs fn under the timeout of the named long-running step and emits
+// start/finish events around it.
+func (r *run) step(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, cancel := r.opts.withTimeout(ctx, name)
+	defer cancel()
+	r.emit(Event{Type: EventStepStarted, Step: name})
+	err := fn(ctx)
+	r.emit(Event{Type: EventStepFinished, Step: name})
+	return r.opts.stepErr(ctx, name, err)
+}
+
+// interrupted reports whether ctx was cancelled, stopping the run as
+// AnswerQuit does the first time.
+func (r *run) interrupted(ctx context.Context) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if !r.result.Quit {
+		r.stop(true)
+	}
+	return true
+}
+
+// skipped reports whether step was turned off with Options.Skip, left out
+// of Options.Only or needs the network in an offline run. The PR lookup is
+// only left out of Options.Only when nothing that needs it runs.
+func (r *run) skipped(step string) bool {
+	if r.opts.Offline && (step == "fetch" || step == "pull") {
+		return true
+	}
+	if len(r.opts.Only) > 0 && !slices.Contains(r.opts.Only, step) && !r.prsNeeded(step) {
+		return true
+	}
+	return slices.Contains(r.opts.Skip, step)
+}
+
+// prsNeeded reports whether step is prs and Options.Only names a step that
+// classifies with PR data, so the PR lookup runs unless skipped explicitly.
+func (r *run) prsNeeded(step string) bool {
+	return step == "prs" && (slices.Contains(r.opts.Only, "worktrees") || slices.Contains(r.opts.Only, "branches"))
+}
+
+// errStopRepo is returned by confirm when the rest of the repo should be
+// left untouched.
+var errStopRepo = errors.New("stop repo")
+
+// errAskEach is returned by confirm when a PromptGroup was answered with
+// AnswerEach.
+var errAskEach = errors.New("ask each")
+
+// confirm asks Decide for an answer, or applies the auto-mode answer or an
+// earlier yes/no-to-all. It returns errStopRepo when the user skipped the
+// repo or quit.
+func (r *run) confirm(ctx context.Context, p Prompt, auto bool) (bool, error) {
+	if r.opts.Auto {
+		return auto, nil
+	}
+	// Yes/no-to-all covers the cleanup prompts only, so that it never
+	// answers a reset or a rename nobody saw.
+	sticky := p.Kind == PromptWorktree || p.Kind == PromptBranch || p.Kind == PromptGroup
+	if r.all != nil && sticky {
+		return *r.all, nil
+	}
+	if r.decide == nil {
+		return false, nil
+	}
+
+	answer, err := r.decide(ctx, p)
+	if errors.Is(err, ErrAborted) {
+		answer, err = AnswerQuit, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch answer {
+	case AnswerYes:
+		return true, nil
+	case AnswerYesToAll, AnswerNoToAll:
+		all := answer == AnswerYesToAll
+		if sticky {
+			r.all = &all
+		}
+		return all, nil
+	case AnswerSkipRepo:
+		r.stop(false)
+		return false, errStopRepo
+	case AnswerQuit:
+		r.stop(true)
+		return false, errStopRepo
+	case AnswerEach:
+		if p.Kind == PromptGroup {
+			return false, errAskEach
+		}
+		return false, nil
+	case AnswerKeep, AnswerSnooze:
+		r.remember(ctx, p, answer)
+		return false, nil
+	default:
+		return false, nil
+	}
+}
+
+// remember stores a decision to keep the branches p asks about, so later
+// runs do not offer them again. AnswerKeep lasts until a branch moves and
+// AnswerSnooze for Options.Snooze.
+func (r *run) remember(ctx context.Context, p Prompt, answer Answer) {
+	var branches []string
+	if p.Kind == PromptGroup {
+		for _, m := range p.Members {
+			branches = append(branches, m.Branch)
+		}
+	} else if p.Branch != "" {
+		branches = []string{p.Branch}
+	}
+
+	for _, branch := range branches {
+		k := keep{until: time.Now().Add(r.opts.Snooze)}
+		if answer == AnswerKeep {
+			sha, err := gitBranchSHA(ctx, r.dir, branch)
+			if err != nil {
+				r.addErr("remember", "remembering keep for "+branch, err)
+				continue
+			}
+			k = keep{tip: sha}
+		}
+		if err := gitSetKeep(ctx, r.dir, branch, k); err != nil {
+			r.addErr("remember", "remembering keep for "+branch, err)
+			continue
+		}
+		r.emit(Event{Type: EventRemembered, Branch: branch, Message: k.describe()})
+	}
+}
+
+// stop records that the user skipped the rest of the repo or quit the run.
+func (r *run) stop(quit bool) {
+	if quit {
+		r.result.Quit = true
+		r.emit(Event{Type: EventQuit})
+		return
+	}
+	r.result.RepoSkipped = true
+	r.skip(Event{Step: "repo"})
+}
+
+// deleteBranch deletes branch and records it in the result, returning false
+// if a hook vetoed the deletion or it failed.
+func (r *run) deleteBranch(ctx context.Context, branch string) bool {
+	if r.vetoed(ctx, branch) {
+		return false
+	}
+	// A missing SHA only loses the restore hint, so it is not an error.
+	sha, _ := gitBranchSHA(ctx, r.dir, branch)
+	if err := gitDeleteBranch(ctx, r.dir, branch); err != nil {
+		r.addErr("delete-branch", "deleting branch "+branch, err)
+		return false
+	}
+	r.result.BranchesDeleted++
+	r.result.DeletedBranches = append(r.result.DeletedBranches, DeletedBranch{
+		Name: branch,
+		SHA:  sha,
+		PR:   prNumber(r.prs, branch),
+	})
+	r.emit(Event{Type: EventBranchDeleted, Branch: branch, SHA: sha, PR: prFor(r.prs, branch)})
+	return true
+}
+
+// prNumber returns the number of the PR for branch, or 0 if there is none.
+func prNumber(prs map[string]PR, branch string) int {
+	if pr, ok := prs[branch]; ok {
+		return pr.Number
+	}
+	return 0
+}
+
+// prFor returns the PR for branch, or nil if there is none.
+func prFor(prs map[string]PR, branch string) *PR {
+	if pr, ok := prs[branch]; ok {
+		return &pr
+	}
+	return nil
+}
+
+// Run cleans the repository at repoPath and returns a report of what was
+// done. Errors from individual steps are collected in Result.Errors rather
+// than stopping the run.
+func (c *Cleaner) Run(ctx context.Context, repoPath string, opts Options) Result {
+	absDir, err := filepath.Abs(repoPath)
+	if err != nil {
+		return Result{Path: repoPath, Errors: []StepError{{Step: "resolve", Message: fmt.Sprintf("resolving path: %v", err)}}}
+	}
+
+	r := &run{
+		dir:    absDir,
+		opts:   opts.withDefaults(),
+		decide: c.Decide,
+		sel:    c.Select,
+		obs:    c.Observer,
+		result: Result{
+			Name:             filepath.Base(absDir),
+			Path:             absDir,
+			RemovedWorktrees: []RemovedWorktree{},
+			DeletedBranches:  []DeletedBranch{},
+			Errors:           []StepError{},
+		},
+	}
+
+	r.clean(ctx)
+	if r.setUp && ctx.Err() == nil {
+		if err := r.hook(ctx, "after_repo", r.opts.Hooks.AfterRepo, hookTarget{}); err != nil {
+			r.addErr("hook", "running after_repo hook", err)
+		}
+	}
+
+	result := r.result
+	r.emit(Event{Type: EventRepoFinished, Result: &result})
+	return r.result
+}
+
+// pipelineStep is a named step of the pipeline. run returns errStopRepo to
+// leave the rest of the repo untouched; other failures are recorded with
+// addErr and do not stop the run.
+type pipelineStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// pipeline returns the steps of a run in order: Steps, then the plugin
+// steps.
+func (r *run) pipeline() []pipelineStep {
+	steps := []pipelineStep{
+		{"reset", r.reset},
+		{"switch", r.switchDefault},
+		{"fetch", r.fetch},
+		{"pull", r.pull},
+		{"prs", r.loadPRs},
+		{"worktrees", r.worktrees},
+		{"branches", r.branches},
+	}
+	for _, p := range r.opts.Plugins {
+		steps = append(steps, pipelineStep{p.Name, r.runPlugin(p)})
+	}
+	return steps
+}
+
+func (r *run) clean(ctx context.Context) {
+	r.prs = map[string]PR{}
+
+	defaultBranch, source, err := r.opts.defaultBranch(ctx, r.dir)
+	if err != nil {
+		r.emit(Event{Type: EventRepoStarted})
+		r.addErr("default-branch", "detecting default branch", err)
+	} else {
+		r.defaultBranch = defaultBranch
+		r.result.DefaultBranch = defaultBranch
+		started := Event{Type: EventRepoStarted, Branch: defaultBranch}
+		if source == sourceInit {
+			started.Message = "guessed from init.defaultBranch"
+		}
+		r.emit(started)
+		// The switch step may be skipped, so pull checks where HEAD is.
+		current, _ := gitCurrentBranch(ctx, r.dir)
+		r.onDefaultBranch = current == defaultBranch
+	}
+
+	if source == sourceLsRemote || source == sourceForge {
+		if err := r.setHead(ctx); err != nil {
+			return
+		}
+	}
+	if old := r.renamedFrom(ctx); old != "" {
+		if err := r.renameDefault(ctx, old); err != nil {
+			return
+		}
+	}
+
+	r.setUp = true
+	if err := r.hook(ctx, "before_repo", r.opts.Hooks.BeforeRepo, hookTarget{}); err != nil {
+		r.addErr("hook", "running before_repo hook", err)
+	}
+
+	for _, s := range r.pipeline() {
+		if r.skipped(s.name) {
+			continue
+		}
+		if r.interrupted(ctx) {
+			return
+		}
+		if err := s.run(ctx); err != nil {
+			return
+		}
+	}
+}
+
+// setHead offers to record the default branch as refs/remotes/<remote>/HEAD
+// when it had to be found on the remote or forge.
+func (r *run) setHead(ctx context.Context) error {
+	confirmed, err := r.confirm(ctx, Prompt{
+		Kind:          PromptSetHead,
+		Title:         fmt.Sprintf("Set %s/HEAD to %s?", r.opts.Remote, r.defaultBranch),
+		Default:       true,
+		Repo:          r.dir,
+		DefaultBranch: r.defaultBranch,
+		Remote:        r.opts.Remote,
+	}, true)
+	if errors.Is(err, errStopRepo) {
+		return err
+	} else if err != nil {
+		r.addErr("prompt", "prompting for "+r.opts.Remote+"/HEAD", err)
+	} else if !confirmed {
+		r.skip(Event{Step: "set-head"})
+	} else if err := gitSetRemoteHEAD(ctx, r.dir, r.opts.Remote, r.defaultBranch); err != nil {
+		r.addErr("set-head", "setting "+r.opts.Remote+"/HEAD", err)
+	} else {
+		r.emit(Event{Type: EventHeadSet, Branch: r.defaultBranch, Message: r.opts.Remote})
+	}
+	return nil
+}
+
+// reset offers to discard uncommitted changes.
+func (r *run) reset(ctx context.Context) error {
+	if !gitHasUncommittedChanges(ctx, r.dir) {
+		return nil
+	}
+
+	r.emit(Event{Type: EventUncommitted})
+	confirmed, err := r.confirm(ctx, Prompt{
+		Kind:          PromptReset,
+		Title:         "Reset HEAD and discard all changes?",
+		Repo:          r.dir,
+		DefaultBranch: r.defaultBranch,
+		Remote:        r.opts.Remote,
+	}, false)
+	if errors.Is(err, errStopRepo) {
+		return err
+	} else if err != nil {
+		r.addErr("prompt", "prompting for reset", err)
+	} else if !confirmed {
+		r.skip(Event{Step: "reset"})
+	} else if err := gitResetHard(ctx, r.dir); err != nil {
+		r.addErr("reset", "resetting HEAD", err)
+	} else {
+		r.emit(Event{Type: EventReset})
+	}
+	return nil
+}
+
+// switchDefault checks out the default branch.
+func (r *run) switchDefault(ctx context.Context) error {
+	if r.defaultBranch == "" {
+		return nil
+	}
+	if err := gitSwitch(ctx, r.dir, r.defaultBranch); err != nil {
+		r.addErr("switch", "switching to "+r.defaultBranch, err)
+	} else {
+		r.emit(Event{Type: EventSwitched, Branch: r.defaultBranch})
+		r.onDefaultBranch = true
+	}
+	return nil
+}
+
+// fetch fetches all remotes with pruning.
+func (r *run) fetch(ctx context.Context) error {
+	if err := r.retryStep(ctx, "fetch", func(ctx context.Context) error { return gitFetchAll(ctx, r.dir) }); err != nil {
+		r.addErr("fetch", "fetching", err)
+		return nil
+	}
+	r.emit(Event{Type: EventFetched})
+	return r.followRename(ctx)
+}
+
+// pull pulls the default branch, but only when it is checked out.
+func (r *run) pull(ctx context.Context) error {
+	if !r.onDefaultBranch {
+		return nil
+	}
+	if err := r.retryStep(ctx, "pull", func(ctx context.Context) error {
+		return gitPull(ctx, r.dir, r.opts.Remote, r.defaultBranch, r.opts.Pull)
+	}); err != nil {
+		r.addErr("pull", "pulling "+r.defaultBranch, err)
+	} else {
+		r.emit(Event{Type: EventPulled, Branch: r.defaultBranch, Message: r.opts.Pull})
+	}
+	return nil
+}
+
+// loadPRs looks up the PRs of the repo's branches and caches them for
+// offline runs, or reads the cache when offline.
+func (r *run) loadPRs(ctx context.Context) error {
+	if r.opts.Offline {
+		prs, fetched, err := loadPRCache(ctx, r.dir)
+		if err != nil {
+			r.addErr("prs", "loading cached PRs", err)
+			return nil
+		}
+		r.prs = prs
+		r.result.PRsFound = len(prs)
+		msg := "no cached PR data"
+		if !fetched.IsZero() {
+			msg = "cached " + fetched.Local().Format("2006-01-02 15:04")
+		}
+		r.emit(Event{Type: EventPRsLoaded, Count: len(prs), Message: msg})
+		return nil
+	}
+
+	var prs map[string]PR
+	err := r.retryStep(ctx, "prs", func(ctx context.Context) error {
+		var err error
+		prs, err = ghFetchPRs(ctx, r.dir)
+		return err
+	})
+	if err != nil {
+		r.addErr("prs", "fetching PRs", err)
+		return nil
+	}
+	if prs != nil {
+		r.prs = prs
+		if err := savePRCache(ctx, r.dir, prs); err != nil {
+			r.addErr("prs", "caching PRs", err)
+		}
+	}
+	r.result.PRsFound = len(r.prs)
+	r.emit(Event{Type: EventPRsLoaded, Count: len(r.prs)})
+	return nil
+}
+
+// plan holds the worktree and branch prompts of a run. It is built once,
+// before the first of the worktrees and branches steps, so a Select
+// callback can see every prompt at once.
+type plan struct {
+	// branchSet holds the local branches other than the default branch.
+	branchSet map[string]struct{}
+	worktrees []Prompt
+	branches  []Prompt
+	// worktreesListed is false if listing worktrees failed.
+	worktreesListed bool
+	// selected holds the Select decision for each prompt across worktrees
+	// and branches, or nil if Select was not used.
+	selected []bool
+}
+
+// prepare builds r.plan unless an earlier step already did.
+func (r *run) prepare(ctx context.Context) error {
+	if r.plan != nil {
+		return nil
+	}
+	p := &plan{}
+	r.plan = p
+	defaultBranch := r.defaultBranch
+
+	// List branches early so we can detect worktree+branch overlap
+	excludeBranch := defaultBranch
+	if excludeBranch == "" {
+		excludeBranch = "__none__"
+	}
+	branches, err := gitListBranches(ctx, r.dir, excludeBranch)
+	if err != nil {
+		r.addErr("list-branches", "listing branches", err)
+	}
+
+	p.branchSet = make(map[string]struct{}, len(branches))
+	for _, b := range branches {
+		p.branchSet[b] = struct{}{}
+	}
+
+	classifier := newClassifier(ctx, r.dir, defaultBranch, r.prs, r.opts)
+
+	newPrompt := func(kind PromptKind, title, branch string) Prompt {
+		class := Classification{Status: StatusUnknown, Reasons: []string{"worktree has no branch"}}
+		if branch != "" {
+			class = classifier.classify(ctx, branch)
+		}
+		p := Prompt{
+			Kind:          kind,
+			Title:         title,
+			Repo:          r.dir,
+			DefaultBranch: defaultBranch,
+			Remote:        r.opts.Remote,
+			Branch:        branch,
+			PR:            prFor(r.prs, branch),
+			LastCommit:    classifier.dates[branch],
+			Class:         class,
+			Merged:        class.Status == StatusMergedPR,
+			Ahead:         -1,
+		}
+		// A PR closed without merging was usually abandoned on purpose.
+		p.Default = class.Status.Deletable() ||
+			class.Status == StatusUnknown && p.PR != nil && p.PR.State == "CLOSED"
+		return p
+	}
+
+	if !r.skipped("worktrees") {
+		if err := gitPruneWorktrees(ctx, r.dir); err != nil {
+			r.addErr("prune-worktrees", "pruning worktrees", err)
+		}
+	}
+
+	// Worktrees are listed even when their step is skipped, since the
+	// branches checked out in them cannot be deleted on their own.
+	worktrees, err := gitListWorktrees(ctx, r.dir)
+	p.worktreesListed = err == nil
+	if err != nil {
+		r.addErr("list-worktrees", "listing worktrees", err)
+	}
+	inWorktree := make(map[string]struct{}, len(worktrees)+1)
+	for _, wt := range worktrees {
+		inWorktree[wt.Branch] = struct{}{}
+	}
+	// The list leaves out the repo's own worktree, whose branch is still
+	// checked out when the switch step is skipped or failed.
+	if current, _ := gitCurrentBranch(ctx, r.dir); current != "" {
+		inWorktree[current] = struct{}{}
+	}
+
+	if !r.skipped("worktrees") {
+		// A worktree's branch is deleted with it, unless the branches step
+		// is skipped.
+		for _, wt := range worktrees {
+			title := "Remove worktree?"
+			if r.withBranch(wt.Branch) {
+				title = "Remove worktree and delete branch?"
+			}
+			wp := newPrompt(PromptWorktree, title, wt.Branch)
+			wp.Worktree = wt.Path
+			wp.Modified = classifier.classifyWorktree(ctx, &wp.Class, wt.Branch, wt.Path)
+			classifier.protectWorktree(&wp.Class, wt.Path)
+			p.worktrees = append(p.worktrees, wp)
+		}
+	}
+
+	// Branches checked out in a worktree are handled with their worktree,
+	// and the current branch is left alone.
+	if !r.skipped("branches") {
+		for _, b := range branches {
+			if _, ok := inWorktree[b]; !ok {
+				p.branches = append(p.branches, newPrompt(PromptBranch, "Delete branch?", b))
+			}
+		}
+	}
+
+	// Protected and kept items are never offered, so the selection list
+	// leaves them out and offeredIndex maps its entries back to their index
+	// across both prompt lists.
+	var offered []Prompt
+	var offeredIndex []int
+	for i, item := range append(p.worktrees[:len(p.worktrees):len(p.worktrees)], p.branches...) {
+		if item.Class.Offered() {
+			offered = append(offered, item)
+			offeredIndex = append(offeredIndex, i)
+		}
+	}
+
+	if r.opts.Auto || r.sel == nil || len(offered) == 0 {
+		return nil
+	}
+
+	if defaultBranch != "" {
+		// Ahead counts are only useful for sorting a selection list,
+		// and a failure just leaves them unknown.
+		ahead, _ := gitAheadCounts(ctx, r.dir, r.opts.Remote+"/"+defaultBranch, branches)
+		for i := range offered {
+			if n, ok := ahead[offered[i].Branch]; ok {
+				offered[i].Ahead = n
+			}
+		}
+	}
+	values, err := r.sel(ctx, offered)
+	if errors.Is(err, ErrAborted) {
+		r.stop(true)
+		return errStopRepo
+	} else if err != nil {
+		r.addErr("prompt", "selecting items", err)
+		return errStopRepo
+	}
+	p.selected = make([]bool, len(p.worktrees)+len(p.branches))
+	for j, i := range offeredIndex {
+		p.selected[i] = values[j]
+	}
+	return nil
+}
+
+// withBranch reports whether branch is removed together with its worktree.
+func (r *run) withBranch(branch string) bool {
+	_, ok := r.plan.branchSet[branch]
+	return ok && !r.skipped("branches")
+}
+
+// answer returns the decision for the i-th prompt across the worktree and
+// branch prompts of the plan.
+func (r *run) answer(ctx context.Context, i int, p Prompt) (bool, error) {
+	if !p.Class.Offered() {
+		return false, nil
+	}
+	if r.plan.selected != nil {
+		return r.plan.selected[i], nil
+	}
+	return r.confirm(ctx, p, r.opts.Policy.Deletes(p.Class, p.PR))
+}
+
+// worktrees offers to remove each worktree, along with its branch.
+func (r *run) worktrees(ctx context.Context) error {
+	if err := r.prepare(ctx); err != nil {
+		return err
+	}
+	if !r.plan.worktreesListed {
+		return nil
+	}
+
+	result := &r.result
+	result.WorktreesTotal = len(r.plan.worktrees)
+	r.emit(Event{Type: EventWorktreesListed, Count: len(r.plan.worktrees)})
+
+	for i, p := range r.plan.worktrees {
+		if r.interrupted(ctx) {
+			return errStopRepo
+		}
+		withBranch := r.withBranch(p.Branch)
+
+		found := Event{Type: EventWorktreeFound, Worktree: p.Worktree, PR: p.PR, Class: &p.Class}
+		if withBranch {
+			found.Branch = p.Branch
+		}
+		r.emit(found)
+
+		confirmed, err := r.answer(ctx, i, p)
+		if errors.Is(err, errStopRepo) {
+			return err
+		} else if err != nil {
+			r.addErr("prompt", "prompting for worktree removal", err)
+			continue
+		}
+
+		if !confirmed {
+			r.skip(Event{Worktree: p.Worktree, Branch: p.Branch})
+			result.WorktreesSkipped++
+			continue
+		}
+
+		// The worktree may have been edited since it was classified, and
+		// nobody looked at it before an auto removal.
+		if r.opts.Auto {
+			if changes, err := gitWorktreeChanges(ctx, p.Worktree); err != nil || len(changes) > 0 {
+				r.skip(Event{Worktree: p.Worktree, Branch: p.Branch})
+				result.WorktreesSkipped++
+				continue
+			}
+		}
+
+		if err := gitRemoveWorktree(ctx, r.dir, p.Worktree); err != nil {
+			r.addErr("remove-worktree", "removing worktree "+p.Worktree, err)
+		} else {
+			result.WorktreesRemoved++
+			result.RemovedWorktrees = append(result.RemovedWorktrees, RemovedWorktree{
+				Path:   p.Worktree,
+				Branch: p.Branch,
+				PR:     prNumber(r.prs, p.Branch),
+			})
+			r.emit(Event{Type: EventWorktreeRemoved, Worktree: p.Worktree, Branch: p.Branch, PR: p.PR})
+			t := hookTarget{branch: p.Branch, worktree: p.Worktree, pr: p.PR}
+			if err := r.hook(ctx, "after_remove_worktree", r.opts.Hooks.AfterRemoveWorktree, t); err != nil {
+				r.addErr("hook", "running after_remove_worktree hook for "+p.Worktree, err)
+			}
+		}
+
+		if withBranch {
+			r.deleteBranch(ctx, p.Branch)
+		}
+	}
+	return nil
+}
+
+// branches offers to delete each branch not checked out in a worktree.
+func (r *run) branches(ctx context.Context) error {
+	if err := r.prepare(ctx); err != nil {
+		return err
+	}
+
+	result := &r.result
+	branchPrompts := r.plan.branches
+	result.BranchesTotal = len(branchPrompts)
+	r.emit(Event{Type: EventBranchesListed, Count: len(branchPrompts)})
+
+	// Large prefix groups, such as dependency bot branches, get a single
+	// decision before their first member. Auto mode and a selection
+	// already decide per branch.
+	var groups map[string][]Prompt
+	if !r.opts.Auto && r.plan.selected == nil {
+		groups = groupPrompts(branchPrompts)
+	}
+	groupAnswers := make(map[string]bool)
+
+	for i, p := range branchPrompts {
+		if r.interrupted(ctx) {
+			return errStopRepo
+		}
+		group := BranchGroup(p.Branch)
+		if members, ok := groups[group]; ok {
+			delete(groups, group)
+			confirmed, err := r.confirm(ctx, newGroupPrompt(group, members), false)
+			switch {
+			case errors.Is(err, errAskEach):
+			case errors.Is(err, errStopRepo):
+				return err
+			case err != nil:
+				r.addErr("prompt", "prompting for branch group "+group, err)
+			default:
+				groupAnswers[group] = confirmed
+			}
+		}
+
+		r.emit(Event{Type: EventBranchFound, Branch: p.Branch, PR: p.PR, Class: &p.Class})
+
+		confirmed, ok := groupAnswers[group]
+		if !ok || !p.Class.Offered() || confirmed && !groupCovers(p) {
+			var err error
+			confirmed, err = r.answer(ctx, len(r.plan.worktrees)+i, p)
+			if errors.Is(err, errStopRepo) {
+				return err
+			} else if err != nil {
+				r.addErr("prompt", "prompting for branch deletion", err)
+				continue
+			}
+		}
+
+		if confirmed {
+			r.deleteBranch(ctx, p.Branch)
+		} else {
+			r.skip(Event{Branch: p.Branch})
+			result.BranchesSkipped++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gitOutput runs git in dir and returns its trimmed output.
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// localBranches returns the local branches of the repo at dir.
+func localBranches(t *testing.T, dir string) []string {
+	t.Helper()
+	return strings.Fields(gitOutput(t, dir, "branch", "--format=%(refname:short)"))
+}
+
+// mergedBranch creates branch with a commit of its own that is then merged
+// into main and pushed, so it classifies as ancestor-of-default.
+func mergedBranch(t *testing.T, dir, branch string) {
+	t.Helper()
+	git(t, dir, "switch", "-q", "-c", branch)
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", branch+" work")
+	git(t, dir, "switch", "-q", "main")
+	git(t, dir, "merge", "-q", "--ff-only", branch)
+	git(t, dir, "push", "-q", "origin", "main")
+}
+
+// renamedRepo returns a clone whose remote renamed its default branch from
+// master to main, leaving master checked out locally.
+func renamedRepo(t *testing.T) string {
+	t.Helper()
+	dir := testRepo(t)
+	git(t, dir, "switch", "-q", "-c", "master")
+	git(t, dir, "push", "-q", "-u", "origin", "master")
+	git(t, dir, "branch", "-q", "-D", "main")
+	git(t, dir, "push", "-q", "origin", "--delete", "master")
+	git(t, dir, "fetch", "-q", "--prune")
+	return dir
+}
+
+// recorder is a DecideFunc that answers every prompt with answer and
+// records the branches it was asked about.
+type recorder struct {
+	answer Answer
+	asked  []string
+}
+
+func (rec *recorder) decide(_ context.Context, p Prompt) (Answer, error) {
+	rec.asked = append(rec.asked, p.Branch)
+	return rec.answer, nil
+}
+
+func TestRunKeepsCurrentBranch(t *testing.T) {
+	dir := testRepo(t)
+	mergedBranch(t, dir, "feat")
+	mergedBranch(t, dir, "done")
+	git(t, dir, "switch", "-q", "feat")
+
+	ctx := context.Background()
+	opts := Options{Offline: true, Only: []string{"branches"}, Policy: AutoPolicy{MergedLocally: true}}
+
+	rec := &recorder{answer: AnswerNo}
+	(&Cleaner{Decide: rec.decide}).Run(ctx, dir, opts)
+	if slices.Contains(rec.asked, "feat") {
+		t.Errorf("prompted for the current branch: %q", rec.asked)
+	}
+
+	opts.Auto = true
+	result := (&Cleaner{}).Run(ctx, dir, opts)
+	if len(result.Errors) > 0 {
+		t.Errorf("Run() errors = %v", result.Errors)
+	}
+	if got, want := localBranches(t, dir), []string{"feat", "main"}; !slices.Equal(got, want) {
+		t.Errorf("branches after auto run = %q, want %q", got, want)
+	}
+}
+
+func TestRunOnlyAndSkip(t *testing.T) {
+	tests := []struct {
+		name       string
+		only, skip []string
+		want       []string
+	}{
+		{"only worktrees", []string{"worktrees"}, nil, []string{"done", "main"}},
+		{"skip branches", nil, []string{"branches"}, []string{"done", "main"}},
+		{"only branches", []string{"branches"}, nil, []string{"main"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testRepo(t)
+			mergedBranch(t, dir, "done")
+
+			opts := Options{Auto: true, Offline: true, Only: tt.only, Skip: tt.skip, Policy: AutoPolicy{MergedLocally: true}}
+			result := (&Cleaner{}).Run(context.Background(), dir, opts)
+			if len(result.Errors) > 0 {
+				t.Errorf("Run() errors = %v", result.Errors)
+			}
+			if got := localBranches(t, dir); !slices.Equal(got, tt.want) {
+				t.Errorf("branches = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunGroupYesAsksAboutUnpushed(t *testing.T) {
+	dir := testRepo(t)
+	mergedBranch(t, dir, "feature/a")
+	mergedBranch(t, dir, "feature/b")
+	git(t, dir, "switch", "-q", "-c", "feature/c")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "local work")
+	git(t, dir, "switch", "-q", "main")
+
+	var title string
+	var asked []string
+	decide := func(_ context.Context, p Prompt) (Answer, error) {
+		if p.Kind == PromptGroup {
+			title = p.Title
+			return AnswerYes, nil
+		}
+		asked = append(asked, p.Branch)
+		return AnswerNo, nil
+	}
+	result := (&Cleaner{Decide: decide}).Run(context.Background(), dir, Options{Offline: true})
+	if len(result.Errors) > 0 {
+		t.Errorf("Run() errors = %v", result.Errors)
+	}
+
+	if want := "Delete 2 of 3 branches in feature/ (0 merged; asking separately about 1 with unpushed work)?"; title != want {
+		t.Errorf("group title = %q, want %q", title, want)
+	}
+	if !slices.Equal(asked, []string{"feature/c"}) {
+		t.Errorf("asked about %q, want only feature/c", asked)
+	}
+	if got, want := localBranches(t, dir), []string{"feature/c", "main"}; !slices.Equal(got, want) {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	dir := testRepo(t)
+	mergedBranch(t, dir, "done")
+	mergedBranch(t, dir, "vetoed")
+	log := filepath.Join(t.TempDir(), "hooks.log")
+
+	opts := Options{
+		Auto:    true,
+		Offline: true,
+		Policy:  AutoPolicy{MergedLocally: true},
+		Hooks: Hooks{
+			BeforeRepo:         []string{"echo before_repo >> " + log},
+			AfterRepo:          []string{"echo after_repo >> " + log},
+			BeforeDeleteBranch: []string{`echo "before_delete_branch $TIDYGIT_BRANCH" >> ` + log + `; test "$TIDYGIT_BRANCH" != vetoed`},
+		},
+	}
+	result := (&Cleaner{}).Run(context.Background(), dir, opts)
+	if len(result.Errors) > 0 {
+		t.Errorf("Run() errors = %v", result.Errors)
+	}
+
+	if got, want := localBranches(t, dir), []string{"main", "vetoed"}; !slices.Equal(got, want) {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+	out, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "before_repo\nbefore_delete_branch done\nbefore_delete_branch vetoed\nafter_repo\n"
+	if string(out) != want {
+		t.Errorf("hooks ran:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRunSkippedAtSetupRunsNoRepoHooks(t *testing.T) {
+	dir := renamedRepo(t)
+	log := filepath.Join(t.TempDir(), "hooks.log")
+
+	var asked []PromptKind
+	decide := func(_ context.Context, p Prompt) (Answer, error) {
+		asked = append(asked, p.Kind)
+		return AnswerSkipRepo, nil
+	}
+	opts := Options{
+		Offline: true,
+		Hooks: Hooks{
+			BeforeRepo: []string{"echo before_repo >> " + log},
+			AfterRepo:  []string{"echo after_repo >> " + log},
+		},
+	}
+	result := (&Cleaner{Decide: decide}).Run(context.Background(), dir, opts)
+
+	if !result.RepoSkipped || !slices.Equal(asked, []PromptKind{PromptRenameDefault}) {
+		t.Errorf("asked %v, skipped %v; want a rename prompt that skips the repo", asked, result.RepoSkipped)
+	}
+	if out, err := os.ReadFile(log); !os.IsNotExist(err) {
+		t.Errorf("hooks ran for a skipped repo: %q", out)
+	}
+}
+
+func TestRunKeepAndSnooze(t *testing.T) {
+	dir := testRepo(t)
+	mergedBranch(t, dir, "kept")
+	mergedBranch(t, dir, "snoozed")
+	ctx := context.Background()
+
+	decide := func(_ context.Context, p Prompt) (Answer, error) {
+		if p.Branch == "kept" {
+			return AnswerKeep, nil
+		}
+		return AnswerSnooze, nil
+	}
+	(&Cleaner{Decide: decide}).Run(ctx, dir, Options{Offline: true})
+
+	auto := Options{Auto: true, Offline: true, Policy: AutoPolicy{MergedLocally: true}}
+	result := (&Cleaner{}).Run(ctx, dir, auto)
+	if len(result.Errors) > 0 {
+		t.Errorf("Run() errors = %v", result.Errors)
+	}
+	if got, want := localBranches(t, dir), []string{"kept", "main", "snoozed"}; !slices.Equal(got, want) {
+		t.Errorf("branches after remembering = %q, want %q", got, want)
+	}
+
+	// Keeping lasts until the branch moves.
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "more")
+	git(t, dir, "push", "-q", "origin", "main")
+	git(t, dir, "branch", "-f", "kept", "main")
+	(&Cleaner{}).Run(ctx, dir, auto)
+	if got, want := localBranches(t, dir), []string{"main", "snoozed"}; !slices.Equal(got, want) {
+		t.Errorf("branches after kept moved = %q, want %q", got, want)
+	}
+}
+
+func TestRunOfflineUsesPRCache(t *testing.T) {
+	dir := testRepo(t)
+	git(t, dir, "switch", "-q", "-c", "feat")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "feat work")
+	git(t, dir, "push", "-q", "-u", "origin", "feat")
+	git(t, dir, "switch", "-q", "main")
+	git(t, dir, "branch", "open")
+
+	ctx := context.Background()
+	prs := map[string]PR{
+		"feat": {Number: 7, Branch: "feat", State: "MERGED"},
+		"open": {Number: 8, Branch: "open", State: "OPEN"},
+	}
+	if err := savePRCache(ctx, dir, prs); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded Event
+	obs := ObserverFunc(func(e Event) {
+		if e.Type == EventPRsLoaded {
+			loaded = e
+		}
+	})
+	result := (&Cleaner{Observer: obs}).Run(ctx, dir, Options{Auto: true, Offline: true})
+	if len(result.Errors) > 0 {
+		t.Errorf("Run() errors = %v", result.Errors)
+	}
+	if result.PRsFound != 2 || !strings.HasPrefix(loaded.Message, "cached ") {
+		t.Errorf("PRs found = %d, loaded %q; want 2 from the cache", result.PRsFound, loaded.Message)
+	}
+	if len(result.DeletedBranches) != 1 || result.DeletedBranches[0].PR != 7 {
+		t.Errorf("deleted %+v, want feat with PR 7", result.DeletedBranches)
+	}
+	if got, want := localBranches(t, dir), []string{"main", "open"}; !slices.Equal(got, want) {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+}
+
+func TestRunDefaultBranchFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		offline bool
+		init    string
+		want    string
+		message string
+		head    bool
+	}{
+		{name: "ls-remote", want: "main", head: true},
+		{name: "init.defaultBranch offline", offline: true, init: "main", want: "main", message: "guessed from init.defaultBranch"},
+		{name: "nothing offline", offline: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testRepo(t)
+			git(t, dir, "remote", "set-head", "origin", "--delete")
+			if tt.init != "" {
+				git(t, dir, "config", "init.defaultBranch", tt.init)
+			}
+
+			var started Event
+			obs := ObserverFunc(func(e Event) {
+				if e.Type == EventRepoStarted {
+					started = e
+				}
+			})
+			opts := Options{Auto: true, Offline: tt.offline, Skip: []string{"fetch", "pull", "prs"}}
+			result := (&Cleaner{Observer: obs}).Run(context.Background(), dir, opts)
+
+			if result.DefaultBranch != tt.want || started.Message != tt.message {
+				t.Errorf("default branch %q (%q), want %q (%q)", result.DefaultBranch, started.Message, tt.want, tt.message)
+			}
+			if failed := len(result.Errors) > 0 && result.Errors[0].Step == "default-branch"; failed != (tt.want == "") {
+				t.Errorf("Run() errors = %v", result.Errors)
+			}
+			cmd := exec.Command("git", "symbolic-ref", "-q", "refs/remotes/origin/HEAD")
+			cmd.Dir = dir
+			if head := cmd.Run() == nil; head != tt.head {
+				t.Errorf("origin/HEAD set = %v, want %v", head, tt.head)
+			}
+		})
+	}
+}
+
+func TestRunRenamesDefaultBranch(t *testing.T) {
+	dir := renamedRepo(t)
+
+	var asked []PromptKind
+	decide := func(_ context.Context, p Prompt) (Answer, error) {
+		asked = append(asked, p.Kind)
+		return AnswerYes, nil
+	}
+	result := (&Cleaner{Decide: decide}).Run(context.Background(), dir, Options{Offline: true})
+	if len(result.Errors) > 0 {
+		t.Errorf("Run() errors = %v", result.Errors)
+	}
+
+	if !slices.Equal(asked, []PromptKind{PromptRenameDefault}) {
+		t.Errorf("asked %v, want only the rename prompt", asked)
+	}
+	if got, want := localBranches(t, dir), []string{"main"}; !slices.Equal(got, want) {
+		t.Errorf("branches = %q, want %q", got, want)
+	}
+	if got := gitOutput(t, dir, "rev-parse", "--abbrev-ref", "main@{upstream}"); got != "origin/main" {
+		t.Errorf("main tracks %q, want origin/main", got)
+	}
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RepoConfigFile is the name of the per-repo config file, committed at the
+// repo root to share team policy.
+const RepoConfigFile = ".tidygit.toml"
+
+// Steps are the pipeline steps, in order, that the Only and Skip fields of
+// Config and Options may name.
+var Steps = []string{"reset", "switch", "fetch", "pull", "prs", "worktrees", "branches"}
+
+// PullStrategies are the values accepted for Config.Pull and Options.Pull.
+var PullStrategies = []string{"rebase", "merge", "ff-only"}
+
+// Config is one layer of file or flag configuration. Empty fields leave the
+// value of lower layers in place; see Merge.
+type Config struct {
+	// Remote is the remote to pull from and compare against. Defaults to
+	// origin.
+	Remote string `toml:"remote"`
+	// Protected are patterns as accepted by NewProtection.
+	Protected []string `toml:"protected"`
+	// AutoPolicy are rules as accepted by ParseAutoPolicy.
+	AutoPolicy []string `toml:"auto_policy"`
+	// OlderThan is an age as accepted by ParseAge.
+	OlderThan string `toml:"older_than"`
+	// Pull is one of PullStrategies. Defaults to rebase.
+	Pull string `toml:"pull"`
+	// Only names the Steps to run; every other step is left out.
+	Only []string `toml:"only"`
+	// Skip names Steps to leave out.
+	Skip []string `toml:"skip"`
+	// Snooze is how long a snoozed branch is kept, as accepted by
+	// ParseAge. Defaults to 30 days.
+	Snooze string `toml:"snooze"`
+	// Hooks are commands run before and after actions. Commands from
+	// every layer run, lower layers first.
+	Hooks Hooks `toml:"hooks"`
+	// Plugins are plugin steps as accepted by ResolvePlugin, run after the
+	// built-in Steps. Plugins from every layer run, lower layers first.
+	Plugins []string `toml:"plugins"`
+	// Timeouts are durations as accepted by time.ParseDuration, keyed by
+	// one of TimeoutSteps or a plugin name. "0" means no limit.
+	Timeouts map[string]string `toml:"timeouts"`
+	// Retries is how many more times network steps are tried after a
+	// transient failure. Defaults to 2.
+	Retries *int `toml:"retries"`
+}
+
+// UserConfigPath returns the path of the user-global config file,
+// $XDG_CONFIG_HOME/tidygit/config.toml or ~/.config/tidygit/config.toml.
+func UserConfigPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "tidygit", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "tidygit", "config.toml"), nil
+}
+
+// LoadConfig reads the config file at path. A missing file is an empty
+// Config, not an error.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	md, err := toml.DecodeFile(path, &c)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Config{}, nil
+	} else if err != nil {
+		return Config{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return Config{}, fmt.Errorf("reading %s: unknown key %q", path, undecoded[0].String())
+	}
+	return c, nil
+}
+
+// Merge returns c overlaid with the fields set in over. Protected patterns,
+// hooks and plugins accumulate across layers and timeouts are replaced per
+// step; every other field is replaced.
+func (c Config) Merge(over Config) Config {
+	if over.Remote != "" {
+		c.Remote = over.Remote
+	}
+	c.Protected = append(c.Protected[:len(c.Protected):len(c.Protected)], over.Protected...)
+	if over.AutoPolicy != nil {
+		c.AutoPolicy = over.AutoPolicy
+	}
+	if over.OlderThan != "" {
+		c.OlderThan = over.OlderThan
+	}
+	if over.Pull != "" {
+		c.Pull = over.Pull
+	}
+	if over.Only != nil {
+		c.Only = over.Only
+	}
+	if over.Skip != nil {
+		c.Skip = over.Skip
+	}
+	if over.Snooze != "" {
+		c.Snooze = over.Snooze
+	}
+	c.Hooks = c.Hooks.merge(over.Hooks)
+	if over.Retries != nil {
+		c.Retries = over.Retries
+	}
+	if len(over.Timeouts) > 0 {
+		timeouts := maps.Clone(c.Timeouts)
+		if timeouts == nil {
+			timeouts = make(map[string]string, len(over.Timeouts))
+		}
+		maps.Copy(timeouts, over.Timeouts)
+		c.Timeouts = timeouts
+	}
+	for _, p := range over.Plugins {
+		if !slices.Contains(c.Plugins, p) {
+			c.Plugins = append(c.Plugins[:len(c.Plugins):len(c.Plugins)], p)
+		}
+	}
+	return c
+}
+
+// Options validates c and returns the Options it describes.
+func (c Config) Options() (Options, error) {
+	opts := Options{}.withDefaults()
+	if c.Remote != "" {
+		opts.Remote = c.Remote
+	}
+
+	var err error
+	if opts.Protect, err = NewProtection(c.Protected); err != nil {
+		return opts, err
+	}
+	if opts.Policy, err = ParseAutoPolicy(strings.Join(c.AutoPolicy, ",")); err != nil {
+		return opts, err
+	}
+	if c.OlderThan != "" {
+		if opts.OlderThan, err = ParseAge(c.OlderThan); err != nil {
+			return opts, fmt.Errorf("older_than: %w", err)
+		}
+	}
+	if opts.Policy.Stale && opts.OlderThan == 0 {
+		return opts, errors.New("auto policy rule \"stale\" requires older_than")
+	}
+
+	if c.Pull != "" {
+		if !slices.Contains(PullStrategies, c.Pull) {
+			return opts, fmt.Errorf("unknown pull strategy %q (want %s)", c.Pull, strings.Join(PullStrategies, ", "))
+		}
+		opts.Pull = c.Pull
+	}
+
+	steps := slices.Clone(Steps)
+	for _, entry := range c.Plugins {
+		p, err := ResolvePlugin(entry)
+		if err != nil {
+			return opts, err
+		}
+		if slices.Contains(steps, p.Name) {
+			return opts, fmt.Errorf("plugin %q: step %q already exists", entry, p.Name)
+		}
+		steps = append(steps, p.Name)
+		opts.Plugins = append(opts.Plugins, p)
+	}
+
+	for _, step := range slices.Concat(c.Only, c.Skip) {
+		if !slices.Contains(steps, step) {
+			return opts, fmt.Errorf("unknown step %q (want %s)", step, strings.Join(steps, ", "))
+		}
+	}
+	opts.Only = c.Only
+	opts.Skip = c.Skip
+
+	if c.Retries != nil {
+		switch n := *c.Retries; {
+		case n < 0:
+			return opts, fmt.Errorf("retries: invalid count %d", n)
+		case n == 0:
+			opts.Retries = -1
+		default:
+			opts.Retries = n
+		}
+	}
+
+	for step, value := range c.Timeouts {
+		if !slices.Contains(TimeoutSteps, step) && !slices.ContainsFunc(opts.Plugins, func(p Plugin) bool { return p.Name == step }) {
+			return opts, fmt.Errorf("timeouts: unknown step %q (want %s or a plugin)", step, strings.Join(TimeoutSteps, ", "))
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return opts, fmt.Errorf("timeouts: invalid duration %q for %s", value, step)
+		}
+		if opts.Timeouts == nil {
+			opts.Timeouts = make(map[string]time.Duration)
+		}
+		opts.Timeouts[step] = d
+	}
+
+	if c.Snooze != "" {
+		if opts.Snooze, err = ParseAge(c.Snooze); err != nil {
+			return opts, fmt.Errorf("snooze: %w", err)
+		}
+	}
+
+	opts.Hooks = c.Hooks
+	return opts, nil
+}
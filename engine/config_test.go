@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigMerge(t *testing.T) {
+	two, zero := 2, 0
+	user := Config{
+		Remote:     "upstream",
+		Protected:  []string{"develop"},
+		AutoPolicy: []string{"merged-locally"},
+		OlderThan:  "90d",
+		Skip:       []string{"prs"},
+		Hooks:      Hooks{BeforeRepo: []string{"echo user"}},
+		Plugins:    []string{"lockfile"},
+		Timeouts:   map[string]string{"fetch": "1m", "pull": "1m"},
+		Retries:    &two,
+	}
+	repo := Config{
+		Protected: []string{"release/*"},
+		Pull:      "ff-only",
+		Skip:      []string{},
+		Hooks:     Hooks{BeforeRepo: []string{"echo repo"}},
+		Plugins:   []string{"lockfile", "cache"},
+		Timeouts:  map[string]string{"fetch": "5m"},
+		Retries:   &zero,
+	}
+
+	got := user.Merge(repo)
+	want := Config{
+		Remote:     "upstream",
+		Protected:  []string{"develop", "release/*"},
+		AutoPolicy: []string{"merged-locally"},
+		OlderThan:  "90d",
+		Pull:       "ff-only",
+		Skip:       []string{},
+		Hooks:      Hooks{BeforeRepo: []string{"echo user", "echo repo"}},
+		Plugins:    []string{"lockfile", "cache"},
+		Timeouts:   map[string]string{"fetch": "5m", "pull": "1m"},
+		Retries:    &zero,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Merging must not write through to the lower layer.
+	if user.Timeouts["fetch"] != "1m" || len(user.Protected) != 1 || len(user.Plugins) != 1 {
+		t.Errorf("Merge() modified its receiver: %+v", user)
+	}
+}
+
+func TestConfigOptionsRetries(t *testing.T) {
+	zero, neg := 0, -1
+	opts, err := Config{Retries: &zero}.Options()
+	if err != nil || opts.Retries >= 0 {
+		t.Errorf("retries = 0 gave Retries %d, %v, want retries disabled", opts.Retries, err)
+	}
+	if _, err := (Config{Retries: &neg}).Options(); err == nil {
+		t.Error("retries = -1 succeeded, want error")
+	}
+	if _, err := (Config{Timeouts: map[string]string{"nope": "1s"}}).Options(); err == nil {
+		t.Error("unknown timeout step succeeded, want error")
+	}
+}
@@ -0,0 +1,383 @@
+// Package engine implements tidygit's repository cleanup: default branch
+// detection, syncing, PR lookup and removal of merged worktrees and branches.
+//
+// The engine does no terminal I/O of its own. Progress is reported as typed
+// events to an Observer and every destructive action is gated by a DecideFunc, so callers
+// can embed the same logic behind any interface.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrAborted is returned by a DecideFunc or SelectFunc when the user aborts.
+// It is treated like AnswerQuit.
+var ErrAborted = errors.New("user aborted")
+
+// Result is the report for a single repository run.
+type Result struct {
+	Name             string `json:"name"`
+	Path             string `json:"path"`
+	DefaultBranch    string `json:"default_branch,omitempty"`
+	WorktreesTotal   int    `json:"worktrees_total"`
+	WorktreesRemoved int    `json:"worktrees_removed"`
+	WorktreesSkipped int    `json:"worktrees_skipped"`
+	BranchesTotal    int    `json:"branches_total"`
+	BranchesDeleted  int    `json:"branches_deleted"`
+	BranchesSkipped  int    `json:"branches_skipped"`
+	PRsFound         int    `json:"prs_found"`
+
+	// RepoSkipped is set when the user chose to skip the rest of the repo.
+	RepoSkipped bool `json:"repo_skipped,omitempty"`
+	// Quit is set when the user asked to stop the whole run. Callers
+	// processing several repos should not start another one.
+	Quit bool `json:"quit,omitempty"`
+
+	RemovedWorktrees []RemovedWorktree `json:"removed_worktrees"`
+	DeletedBranches  []DeletedBranch   `json:"deleted_branches"`
+	Errors           []StepError       `json:"errors"`
+	// Plugins holds the reports of plugin steps, in the order they ran.
+	Plugins []PluginResult `json:"plugins,omitempty"`
+}
+
+// RemovedWorktree records a worktree removed during a run.
+type RemovedWorktree struct {
+	Path   string `json:"path"`
+	Branch string `json:"branch,omitempty"`
+	PR     int    `json:"pr,omitempty"`
+}
+
+// DeletedBranch records a branch deleted during a run. SHA is the tip the
+// branch pointed at, so it can be restored with `git branch <name> <sha>`.
+type DeletedBranch struct {
+	Name string `json:"name"`
+	SHA  string `json:"sha,omitempty"`
+	PR   int    `json:"pr,omitempty"`
+}
+
+// StepError is an error recorded against a pipeline step. Step is a stable
+// identifier such as "fetch" or "delete-branch"; Message is human-readable.
+type StepError struct {
+	Step    string `json:"step"`
+	Message string `json:"message"`
+}
+
+func (e StepError) Error() string {
+	return e.Message
+}
+
+// PromptKind identifies what a Prompt is asking permission for.
+type PromptKind int
+
+const (
+	PromptReset PromptKind = iota
+	PromptWorktree
+	PromptBranch
+	// PromptGroup asks about every branch sharing a naming prefix at once.
+	PromptGroup
+	// PromptSetHead asks to record the default branch found on the remote
+	// or forge as refs/remotes/<remote>/HEAD, so later runs read it locally.
+	PromptSetHead
+	// PromptRenameDefault asks to rename Branch, left behind by a rename of
+	// the default branch on the remote, to DefaultBranch.
+	PromptRenameDefault
+)
+
+// Prompt describes a single decision the engine needs before acting.
+type Prompt struct {
+	Kind    PromptKind
+	Title   string
+	Default bool
+
+	// Repo is the absolute path of the repository being cleaned.
+	Repo string
+	// DefaultBranch is the repo's default branch, if it was detected.
+	DefaultBranch string
+	// Remote is the remote the default branch is compared against.
+	Remote string
+
+	// Branch is the branch affected by the action, if any.
+	Branch string
+	// Worktree is the worktree path for PromptWorktree.
+	Worktree string
+	// Group is the shared prefix for PromptGroup, e.g. "renovate/", and
+	// Members are the branch prompts it covers.
+	Group   string
+	Members []Prompt
+	// PR is the pull request for Branch, or nil if none was found.
+	PR *PR
+	// LastCommit is the commit date of the branch tip, or zero if unknown.
+	LastCommit time.Time
+	// Modified is when the worktree was last worked in for
+	// PromptWorktree, or zero if unknown.
+	Modified time.Time
+	// Class is how Branch was classified and why.
+	Class Classification
+	// Merged reports whether Branch was classified StatusMergedPR. For
+	// PromptGroup it reports whether every member was.
+	Merged bool
+	// Ahead is the number of commits on Branch that are not on
+	// origin/<DefaultBranch>, or -1 if unknown. It is only computed for
+	// prompts passed to a SelectFunc.
+	Ahead int
+}
+
+// Answer is the response to a Prompt.
+type Answer int
+
+const (
+	AnswerNo Answer = iota
+	AnswerYes
+	// AnswerYesToAll accepts this and every remaining worktree, branch and
+	// group prompt in the repo. For other prompts it is AnswerYes.
+	AnswerYesToAll
+	// AnswerNoToAll declines this and every remaining worktree, branch and
+	// group prompt in the repo. For other prompts it is AnswerNo.
+	AnswerNoToAll
+	// AnswerSkipRepo leaves the rest of the repo untouched.
+	AnswerSkipRepo
+	// AnswerQuit stops the repo and sets Result.Quit.
+	AnswerQuit
+	// AnswerEach answers a PromptGroup by prompting for each member
+	// instead. It is treated as AnswerNo for other prompts.
+	AnswerEach
+	// AnswerKeep declines and remembers to keep the branch until its tip
+	// changes, so later runs do not offer it.
+	AnswerKeep
+	// AnswerSnooze declines and remembers to keep the branch for
+	// Options.Snooze.
+	AnswerSnooze
+)
+
+// DecideFunc answers a Prompt.
+type DecideFunc func(ctx context.Context, p Prompt) (Answer, error)
+
+// SelectFunc answers every worktree and branch prompt of a repo at once. It
+// returns one decision per prompt, in order. Returning ErrAborted stops the
+// run as AnswerQuit does.
+type SelectFunc func(ctx context.Context, prompts []Prompt) ([]bool, error)
+
+// Options configures a single Run.
+type Options struct {
+	// Auto removes the worktrees and branches Policy deletes without
+	// calling Decide and leaves everything else untouched.
+	Auto   bool
+	Policy AutoPolicy
+	// OlderThan, if set, marks branches and worktrees without activity for
+	// this long as stale. See Classification.Stale.
+	OlderThan time.Duration
+	// Protect lists the branches and worktrees that are never offered for
+	// deletion, in addition to the default branch.
+	Protect Protection
+
+	// Remote is the remote to pull from and compare against. Defaults to
+	// origin.
+	Remote string
+	// Pull is the pull strategy, one of PullStrategies. Defaults to rebase.
+	Pull string
+	// Only, if set, names the Steps to run; every other step is left out,
+	// except prs when worktrees or branches run, since they need PR data.
+	Only []string
+	// Skip names Steps to leave out.
+	Skip []string
+	// Snooze is how long AnswerSnooze keeps a branch. Defaults to 30 days.
+	Snooze time.Duration
+	// Hooks are commands run before and after actions.
+	Hooks Hooks
+	// Plugins are external steps run after the built-in ones, in order.
+	Plugins []Plugin
+	// Offline leaves out the fetch and pull steps, reads the default branch
+	// from local refs and uses the PR data cached by the last online run.
+	Offline bool
+	// Timeouts limit how long the commands of a step may run, keyed by one
+	// of TimeoutSteps or a plugin name. Unset steps use DefaultTimeouts,
+	// and plugins without one DefaultPluginTimeout. Zero means no limit.
+	// For fetch, pull and prs the limit applies to each attempt, and for
+	// default-branch to each place the branch is looked up.
+	Timeouts map[string]time.Duration
+	// Retries is how many more times fetch, pull and the PR lookup are
+	// tried after a transient failure, such as a timeout, a DNS error or
+	// a 5xx response. Defaults to 2; a negative value disables retries.
+	Retries int
+}
+
+// TimeoutSteps are the steps, other than plugins, that Options.Timeouts
+// may limit. "hooks" applies to each hook command.
+var TimeoutSteps = []string{"default-branch", "fetch", "pull", "prs", "hooks"}
+
+// DefaultTimeouts are the timeouts of the TimeoutSteps.
+var DefaultTimeouts = map[string]time.Duration{
+	"default-branch": 30 * time.Second,
+	"fetch":          2 * time.Minute,
+	"pull":           2 * time.Minute,
+	"prs":            time.Minute,
+	"hooks":          time.Minute,
+}
+
+// DefaultPluginTimeout is the timeout of plugin steps.
+const DefaultPluginTimeout = 5 * time.Minute
+
+// timeout returns the timeout of step.
+func (o Options) timeout(step string) time.Duration {
+	if d, ok := o.Timeouts[step]; ok {
+		return d
+	}
+	if d, ok := DefaultTimeouts[step]; ok {
+		return d
+	}
+	return DefaultPluginTimeout
+}
+
+// withTimeout returns a context limited by the timeout of step.
+func (o Options) withTimeout(ctx context.Context, step string) (context.Context, context.CancelFunc) {
+	if d := o.timeout(step); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// stepErr explains err, from a command of step run under ctx, by the
+// step's timeout or by cancellation if either killed the command.
+func (o Options) stepErr(ctx context.Context, step string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("timed out after %s: %w", o.timeout(step), context.DeadlineExceeded)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("interrupted: %w", context.Canceled)
+	}
+	return err
+}
+
+// withDefaults fills in the defaults of unset options.
+func (o Options) withDefaults() Options {
+	if o.Remote == "" {
+		o.Remote = "origin"
+	}
+	if o.Pull == "" {
+		o.Pull = "rebase"
+	}
+	if o.Retries == 0 {
+		o.Retries = 2
+	}
+	if o.Snooze == 0 {
+		o.Snooze = 30 * 24 * time.Hour
+	}
+	return o
+}
+
+// Default branch sources, in the order Options.defaultBranch tries them.
+const (
+	sourceRemoteRef = "remote-ref"
+	sourceLsRemote  = "ls-remote"
+	sourceForge     = "forge"
+	sourceInit      = "init.defaultBranch"
+)
+
+// defaultBranch returns the default branch of the repo at dir and where it
+// was found: refs/remotes/<remote>/HEAD, the remote itself, the forge, and
+// last init.defaultBranch. Offline, only the local sources are tried.
+func (o Options) defaultBranch(ctx context.Context, dir string) (string, string, error) {
+	return o.findDefaultBranch(ctx, dir, true)
+}
+
+// remoteDefaultBranch is defaultBranch without the local sources, to look
+// past a refs/remotes/<remote>/HEAD left behind by a rename.
+func (o Options) remoteDefaultBranch(ctx context.Context, dir string) (string, string, error) {
+	return o.findDefaultBranch(ctx, dir, false)
+}
+
+// Each source gets the "default-branch" timeout of its own, so a remote
+// that hangs still leaves time for the sources after it.
+func (o Options) findDefaultBranch(ctx context.Context, dir string, local bool) (string, string, error) {
+	sources := []struct {
+		name   string
+		remote bool
+		find   func(ctx context.Context) (string, error)
+	}{
+		{sourceRemoteRef, false, func(ctx context.Context) (string, error) { return gitLocalDefaultBranch(ctx, dir, o.Remote) }},
+		{sourceLsRemote, true, func(ctx context.Context) (string, error) { return gitRemoteHEAD(ctx, dir, o.Remote) }},
+		{sourceForge, true, func(ctx context.Context) (string, error) { return ghDefaultBranch(ctx, dir) }},
+		{sourceInit, false, func(ctx context.Context) (string, error) { return gitInitDefaultBranch(ctx, dir, o.Remote) }},
+	}
+	var reasons []string
+	for _, s := range sources {
+		if s.remote && o.Offline || !s.remote && !local {
+			continue
+		}
+		sourceCtx, cancel := o.withTimeout(ctx, "default-branch")
+		branch, err := s.find(sourceCtx)
+		err = o.stepErr(sourceCtx, "default-branch", err)
+		cancel()
+		if err == nil {
+			return branch, s.name, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", err
+		}
+		reasons = append(reasons, s.name+": "+err.Error())
+	}
+	if o.Offline {
+		reasons = append(reasons, fmt.Sprintf("run `git remote set-head %s --auto` once online", o.Remote))
+	}
+	return "", "", fmt.Errorf("no default branch found: %s", strings.Join(reasons, "; "))
+}
+
+// Cleaner runs the cleanup pipeline against repositories.
+type Cleaner struct {
+	// Decide is called for every prompt when Options.Auto is false. A nil
+	// Decide declines every prompt.
+	Decide DecideFunc
+	// Select, if set, replaces Decide for worktree and branch prompts with a
+	// single call covering all of them.
+	Select SelectFunc
+	// Observer receives events as the run progresses. A nil Observer
+	// discards them.
+	Observer Observer
+}
+
+// FindRepos returns the absolute paths of the git repositories directly
+// inside dir.
+func FindRepos(dir string) ([]string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving path %s: %w", dir, err)
+	}
+
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %w", absDir, err)
+	}
+
+	var repos []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		repoPath := filepath.Join(absDir, entry.Name())
+		info, err := os.Stat(filepath.Join(repoPath, ".git"))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		repos = append(repos, repoPath)
+	}
+	return repos, nil
+}
+
+// RepoRoot returns the top-level directory of the working tree containing
+// dir.
+func RepoRoot(ctx context.Context, dir string) (string, error) {
+	out, err := gitCmd(ctx, dir, "rev-parse", "--show-toplevel").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("finding repository root: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
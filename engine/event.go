@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType identifies a point in the cleanup pipeline.
+type EventType string
+
+const (
+	EventRepoStarted     EventType = "repo_started"
+	EventRepoFinished    EventType = "repo_finished"
+	EventStepStarted     EventType = "step_started"
+	EventStepFinished    EventType = "step_finished"
+	EventUncommitted     EventType = "uncommitted_changes"
+	EventReset           EventType = "reset"
+	EventHeadSet         EventType = "head_set"
+	EventRenamed         EventType = "renamed"
+	EventSwitched        EventType = "switched"
+	EventFetched         EventType = "fetched"
+	EventPulled          EventType = "pulled"
+	EventPRsLoaded       EventType = "prs_loaded"
+	EventWorktreesListed EventType = "worktrees_listed"
+	EventBranchesListed  EventType = "branches_listed"
+	EventWorktreeFound   EventType = "worktree_found"
+	EventBranchFound     EventType = "branch_found"
+	EventWorktreeRemoved EventType = "worktree_removed"
+	EventBranchDeleted   EventType = "branch_deleted"
+	EventSkipped         EventType = "skipped"
+	EventRemembered      EventType = "remembered"
+	EventHook            EventType = "hook"
+	EventVetoed          EventType = "vetoed"
+	EventPluginAction    EventType = "plugin_action"
+	EventRetrying        EventType = "retrying"
+	EventError           EventType = "error"
+	EventQuit            EventType = "quit"
+)
+
+// Event is a single typed notification emitted while a repo is cleaned.
+// Only the fields relevant to Type are set.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Repo is the absolute path of the repository being cleaned.
+	Repo string `json:"repo"`
+
+	Step     string `json:"step,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Worktree string `json:"worktree,omitempty"`
+	SHA      string `json:"sha,omitempty"`
+	PR       *PR    `json:"pr,omitempty"`
+	Count    int    `json:"count,omitempty"`
+	Message  string `json:"message,omitempty"`
+
+	// Class is set on EventWorktreeFound and EventBranchFound.
+	Class *Classification `json:"classification,omitempty"`
+
+	// Result is set on EventRepoFinished.
+	Result *Result `json:"result,omitempty"`
+}
+
+// Observer receives events as they happen. Observe is called synchronously
+// from the goroutine running Cleaner.Run, so implementations should not
+// block for long.
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc adapts a plain function to the Observer interface.
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// Observers fans events out to every non-nil observer in order.
+func Observers(obs ...Observer) Observer {
+	var list []Observer
+	for _, o := range obs {
+		if o != nil {
+			list = append(list, o)
+		}
+	}
+	return ObserverFunc(func(e Event) {
+		for _, o := range list {
+			o.Observe(e)
+		}
+	})
+}
+
+// NDJSONObserver writes each event to w as a single line of JSON.
+type NDJSONObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewNDJSONObserver returns an Observer that writes newline-delimited JSON.
+func NewNDJSONObserver(w io.Writer) *NDJSONObserver {
+	return &NDJSONObserver{enc: json.NewEncoder(w)}
+}
+
+func (o *NDJSONObserver) Observe(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return
+	}
+	o.err = o.enc.Encode(e)
+}
+
+// Err returns the first write error, if any.
+func (o *NDJSONObserver) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.err
+}
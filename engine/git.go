@@ -0,0 +1,352 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Worktree struct {
+	Path   string
+	Branch string
+}
+
+// gitCmd returns a git command that runs inside dir and is killed when ctx
+// is done. Credential prompts fail instead of waiting for a terminal.
+func gitCmd(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	// Helpers such as ssh may outlive a killed git and hold its output open.
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// gitRemoteCmd returns a gitCmd for a command that contacts a remote. SSH
+// runs in batch mode, so a passphrase or host key prompt fails instead of
+// waiting for a terminal. A custom GIT_SSH program is left alone.
+func gitRemoteCmd(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := gitCmd(ctx, dir, args...)
+	if os.Getenv("GIT_SSH") != "" {
+		return cmd
+	}
+	ssh := os.Getenv("GIT_SSH_COMMAND")
+	if ssh == "" {
+		// GIT_SSH_COMMAND overrides core.sshCommand, so keep its value.
+		out, _ := gitCmd(ctx, dir, "config", "core.sshCommand").Output()
+		ssh = strings.TrimSpace(string(out))
+	}
+	if ssh == "" {
+		ssh = "ssh"
+	}
+	cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+ssh+" -o BatchMode=yes")
+	return cmd
+}
+
+// gitRemoteHEAD asks remote which branch its HEAD points at.
+func gitRemoteHEAD(ctx context.Context, dir, remote string) (string, error) {
+	out, err := gitRemoteCmd(ctx, dir, "ls-remote", "--symref", remote, "HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("asking %s for its HEAD: %s: %w", remote, strings.TrimSpace(string(out)), err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		ref, ok := strings.CutPrefix(line, "ref: refs/heads/")
+		if !ok {
+			continue
+		}
+		if branch, target, ok := strings.Cut(ref, "\t"); ok && target == "HEAD" {
+			return branch, nil
+		}
+	}
+	return "", fmt.Errorf("asking %s for its HEAD: no symbolic HEAD in ls-remote output", remote)
+}
+
+// gitInitDefaultBranch returns init.defaultBranch if remote has a branch of
+// that name.
+func gitInitDefaultBranch(ctx context.Context, dir, remote string) (string, error) {
+	out, _ := gitCmd(ctx, dir, "config", "init.defaultBranch").Output()
+	branch := strings.TrimSpace(string(out))
+	if branch == "" {
+		return "", fmt.Errorf("init.defaultBranch is not set")
+	}
+	ref := "refs/remotes/" + remote + "/" + branch
+	if !gitRefExists(ctx, dir, ref) {
+		return "", fmt.Errorf("init.defaultBranch is %s, but %s does not exist", branch, ref)
+	}
+	return branch, nil
+}
+
+// gitSetRemoteHEAD points refs/remotes/<remote>/HEAD at branch, as
+// `git remote set-head <remote> --auto` would. Unlike set-head, it works
+// before <remote>/<branch> has been fetched.
+func gitSetRemoteHEAD(ctx context.Context, dir, remote, branch string) error {
+	head := "refs/remotes/" + remote + "/HEAD"
+	out, err := gitCmd(ctx, dir, "symbolic-ref", "-m", "tidygit: set-head", head, "refs/remotes/"+remote+"/"+branch).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("setting %s/HEAD: %s: %w", remote, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// gitRefExists reports whether the fully qualified ref exists.
+func gitRefExists(ctx context.Context, dir, ref string) bool {
+	return gitCmd(ctx, dir, "show-ref", "--verify", "--quiet", ref).Run() == nil
+}
+
+// gitRemoteHasBranch asks remote whether it still has branch.
+func gitRemoteHasBranch(ctx context.Context, dir, remote, branch string) (bool, error) {
+	out, err := gitRemoteCmd(ctx, dir, "ls-remote", "--heads", remote, "refs/heads/"+branch).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("asking %s for %s: %s: %w", remote, branch, strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimSpace(string(out)) != "", nil
+}
+
+// gitRenameBranch renames the local branch old to name and makes it track
+// <remote>/<name>. The upstream is set in the config, so it need not have
+// been fetched yet.
+func gitRenameBranch(ctx context.Context, dir, old, name, remote string) error {
+	out, err := gitCmd(ctx, dir, "branch", "-m", old, name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("renaming %s to %s: %s: %w", old, name, strings.TrimSpace(string(out)), err)
+	}
+	for key, value := range map[string]string{
+		"branch." + name + ".remote": remote,
+		"branch." + name + ".merge":  "refs/heads/" + name,
+	} {
+		if out, err := gitCmd(ctx, dir, "config", key, value).CombinedOutput(); err != nil {
+			return fmt.Errorf("setting upstream of %s: %s: %w", name, strings.TrimSpace(string(out)), err)
+		}
+	}
+	return nil
+}
+
+// gitCurrentBranch returns the branch checked out in dir, or "" if HEAD is
+// detached.
+func gitCurrentBranch(ctx context.Context, dir string) (string, error) {
+	out, err := gitCmd(ctx, dir, "branch", "--show-current").Output()
+	if err != nil {
+		return "", fmt.Errorf("getting current branch: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitLocalDefaultBranch returns the default branch of remote as recorded
+// in refs/remotes/<remote>/HEAD, without contacting the remote.
+func gitLocalDefaultBranch(ctx context.Context, dir, remote string) (string, error) {
+	out, err := gitCmd(ctx, dir, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("reading refs/remotes/%s/HEAD: %s: %w", remote, strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), remote+"/"), nil
+}
+
+func gitHasUncommittedChanges(ctx context.Context, dir string) bool {
+	err := gitCmd(ctx, dir, "diff-index", "--quiet", "HEAD", "--").Run()
+	return err != nil
+}
+
+func gitResetHard(ctx context.Context, dir string) error {
+	out, err := gitCmd(ctx, dir, "reset", "--hard", "HEAD").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("resetting HEAD: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+func gitSwitch(ctx context.Context, dir, branch string) error {
+	out, err := gitCmd(ctx, dir, "switch", branch).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("switching to %s: %s: %w", branch, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+func gitFetchAll(ctx context.Context, dir string) error {
+	out, err := gitRemoteCmd(ctx, dir, "fetch", "--all", "--prune").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("fetching: %s: %w", strings.TrimSpace(string(out)), transient(out, err))
+	}
+	return nil
+}
+
+// gitPull pulls branch from remote using strategy, one of PullStrategies.
+func gitPull(ctx context.Context, dir, remote, branch, strategy string) error {
+	flag := map[string]string{"rebase": "--rebase", "merge": "--no-rebase", "ff-only": "--ff-only"}[strategy]
+	out, err := gitRemoteCmd(ctx, dir, "pull", flag, remote, branch).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pulling with %s: %s: %w", strategy, strings.TrimSpace(string(out)), transient(out, err))
+	}
+	return nil
+}
+
+func gitPruneWorktrees(ctx context.Context, dir string) error {
+	out, err := gitCmd(ctx, dir, "worktree", "prune").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pruning worktrees: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+func gitListWorktrees(ctx context.Context, dir string) ([]Worktree, error) {
+	out, err := gitCmd(ctx, dir, "worktree", "list", "--porcelain").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("listing worktrees: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	var worktrees []Worktree
+	var current Worktree
+	first := true
+
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			if first {
+				// Skip the main worktree (first entry)
+				first = false
+				current = Worktree{}
+				continue
+			}
+			current = Worktree{Path: strings.TrimPrefix(line, "worktree ")}
+		case strings.HasPrefix(line, "branch "):
+			if current.Path != "" {
+				current.Branch = strings.TrimPrefix(line, "branch refs/heads/")
+			}
+		case line == "":
+			if current.Path != "" {
+				worktrees = append(worktrees, current)
+				current = Worktree{}
+			}
+		}
+	}
+	// Handle last entry if no trailing newline
+	if current.Path != "" {
+		worktrees = append(worktrees, current)
+	}
+
+	return worktrees, nil
+}
+
+func gitListBranches(ctx context.Context, dir, exclude string) ([]string, error) {
+	out, err := gitCmd(ctx, dir, "branch", "--format=%(refname:short)").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("listing branches: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	var branches []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != exclude {
+			branches = append(branches, line)
+		}
+	}
+	return branches, nil
+}
+
+// gitBranchCommitDates returns the commit date of each local branch tip.
+func gitBranchCommitDates(ctx context.Context, dir string) (map[string]time.Time, error) {
+	out, err := gitCmd(ctx, dir, "for-each-ref", "--format=%(refname:short) %(committerdate:unix)", "refs/heads").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("reading branch dates: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	dates := make(map[string]time.Time)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		name, ts, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+		dates[name] = time.Unix(sec, 0)
+	}
+	return dates, nil
+}
+
+// gitAheadCounts returns how many commits each branch has that base does
+// not. It uses for-each-ref's ahead-behind atom where git supports it
+// (2.41+) and falls back to one rev-list per branch.
+func gitAheadCounts(ctx context.Context, dir, base string, branches []string) (map[string]int, error) {
+	counts := make(map[string]int, len(branches))
+
+	out, err := gitCmd(ctx, dir, "for-each-ref", "--format=%(refname:short) %(ahead-behind:"+base+")", "refs/heads").CombinedOutput()
+	if err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				counts[fields[0]] = n
+			}
+		}
+		return counts, nil
+	}
+
+	for _, b := range branches {
+		out, err := gitCmd(ctx, dir, "rev-list", "--count", base+".."+b).CombinedOutput()
+		if err != nil {
+			return counts, fmt.Errorf("counting commits ahead of %s: %s: %w", base, strings.TrimSpace(string(out)), err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		if err != nil {
+			return counts, fmt.Errorf("counting commits ahead of %s: %w", base, err)
+		}
+		counts[b] = n
+	}
+	return counts, nil
+}
+
+// gitWorktreeChanges returns the paths with uncommitted changes in the
+// worktree at path, untracked files included. It does not refresh the
+// index, so it leaves no trace of its own.
+func gitWorktreeChanges(ctx context.Context, path string) ([]string, error) {
+	out, err := gitCmd(ctx, path, "--no-optional-locks", "status", "--porcelain", "-z", "--untracked-files=all").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("checking %s for changes: %s: %w", path, strings.TrimSpace(string(out)), err)
+	}
+	var changes []string
+	entries := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		changes = append(changes, e[3:])
+		// Renames and copies are followed by their source path.
+		if e[0] == 'R' || e[0] == 'C' {
+			i++
+		}
+	}
+	return changes, nil
+}
+
+func gitRemoveWorktree(ctx context.Context, dir, path string) error {
+	out, err := gitCmd(ctx, dir, "worktree", "remove", path, "--force").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("removing worktree %s: %s: %w", path, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+func gitBranchSHA(ctx context.Context, dir, name string) (string, error) {
+	out, err := gitCmd(ctx, dir, "rev-parse", "--verify", "refs/heads/"+name).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("resolving branch %s: %s: %w", name, strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func gitDeleteBranch(ctx context.Context, dir, name string) error {
+	out, err := gitCmd(ctx, dir, "branch", "-D", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("deleting branch %s: %s: %w", name, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type PR struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Branch string `json:"headRefName"`
+	State  string `json:"state"`
+	// HeadSHA is the last commit pushed to the PR.
+	HeadSHA string `json:"headRefOid"`
+	// ClosedAt is when the PR was closed or merged, zero if it is open.
+	ClosedAt time.Time `json:"closedAt,omitzero"`
+}
+
+// ghCmd returns a gh command that runs inside dir so gh resolves the repo from
+// its remotes. It is killed when ctx is done and never prompts.
+func ghCmd(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GH_PROMPT_DISABLED=1", "GIT_TERMINAL_PROMPT=0")
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// ghDefaultBranch asks the forge for the repo's default branch.
+func ghDefaultBranch(ctx context.Context, dir string) (string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", fmt.Errorf("asking GitHub for the default branch: %w", err)
+	}
+	out, err := ghCmd(ctx, dir, "repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("asking GitHub for the default branch: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "" {
+		return "", fmt.Errorf("asking GitHub for the default branch: no default branch")
+	}
+	return branch, nil
+}
+
+// ghFetchPRs returns a map of branch name to the most recent PR info.
+// Returns a nil map if gh is not installed, not authenticated or cannot
+// list the repo's PRs, unless ctx is done or the failure is transient.
+func ghFetchPRs(ctx context.Context, dir string) (map[string]PR, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return nil, nil
+	}
+
+	if out, err := ghCmd(ctx, dir, "auth", "status").CombinedOutput(); err != nil {
+		if err := transient(out, err); isTransient(err) {
+			return nil, fmt.Errorf("checking gh auth: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil, ctx.Err()
+	}
+
+	out, err := ghCmd(
+		ctx, dir, "pr", "list",
+		"--state", "all",
+		"--json", "headRefName,headRefOid,number,title,url,state,closedAt",
+	).CombinedOutput()
+	if err != nil {
+		if err := transient(out, err); isTransient(err) {
+			return nil, fmt.Errorf("listing PRs: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil, ctx.Err()
+	}
+
+	var prs []PR
+	if err := json.Unmarshal(out, &prs); err != nil {
+		return nil, fmt.Errorf("parsing PR data: %w", err)
+	}
+
+	result := make(map[string]PR, len(prs))
+	for _, pr := range prs {
+		if _, exists := result[pr.Branch]; !exists {
+			result[pr.Branch] = pr
+		}
+	}
+	return result, nil
+}
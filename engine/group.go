@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minGroupSize is the smallest prefix group that gets a single decision for
+// all of its branches.
+const minGroupSize = 3
+
+// BranchGroup returns the naming prefix of branch up to and including the
+// first slash, e.g. "feature/", "renovate/" or a user's initials like "kp/".
+// It returns "" for branches without a prefix.
+func BranchGroup(branch string) string {
+	prefix, _, ok := strings.Cut(branch, "/")
+	if !ok || prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// groupPrompts returns the branch prompts of every prefix group with at
+// least minGroupSize members, keyed by group. Protected and kept branches
+// are left out since they are never offered for deletion.
+func groupPrompts(prompts []Prompt) map[string][]Prompt {
+	all := make(map[string][]Prompt)
+	for _, p := range prompts {
+		if !p.Class.Offered() {
+			continue
+		}
+		if g := BranchGroup(p.Branch); g != "" {
+			all[g] = append(all[g], p)
+		}
+	}
+
+	groups := make(map[string][]Prompt)
+	for g, members := range all {
+		if len(members) >= minGroupSize {
+			groups[g] = members
+		}
+	}
+	return groups
+}
+
+// groupCovers reports whether a yes to its group deletes the branch of p.
+// Branches with unpushed work or an open PR are asked about on their own,
+// so one answer never loses commits that exist nowhere else.
+func groupCovers(p Prompt) bool {
+	return p.Class.Status != StatusUnpushed && p.Class.Status != StatusOpenPR
+}
+
+// newGroupPrompt builds the PromptGroup asking about every branch in group.
+func newGroupPrompt(group string, members []Prompt) Prompt {
+	merged, unpushed, open := 0, 0, 0
+	allDefault := true
+	for _, m := range members {
+		switch {
+		case m.Merged:
+			merged++
+		case m.Class.Status == StatusUnpushed:
+			unpushed++
+		case m.Class.Status == StatusOpenPR:
+			open++
+		}
+		allDefault = allDefault && m.Default
+	}
+
+	title := fmt.Sprintf("Delete all %d branches in %s (%d merged)?", len(members), group, merged)
+	if unpushed+open > 0 {
+		var separate []string
+		if unpushed > 0 {
+			separate = append(separate, fmt.Sprintf("%d with unpushed work", unpushed))
+		}
+		if open > 0 {
+			separate = append(separate, fmt.Sprintf("%d with an open PR", open))
+		}
+		title = fmt.Sprintf("Delete %d of %d branches in %s (%d merged; asking separately about %s)?",
+			len(members)-unpushed-open, len(members), group, merged, strings.Join(separate, " and "))
+	}
+
+	first := members[0]
+	return Prompt{
+		Kind:          PromptGroup,
+		Title:         title,
+		Default:       allDefault,
+		Repo:          first.Repo,
+		DefaultBranch: first.DefaultBranch,
+		Remote:        first.Remote,
+		Group:         group,
+		Members:       members,
+		Merged:        merged == len(members),
+		Ahead:         -1,
+	}
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hooks are shell commands run at points of a run. Each command is run with
+// sh -c in the repo directory, with the environment extended by:
+//
+//	TIDYGIT_HOOK            the hook point, e.g. "before_delete_branch"
+//	TIDYGIT_REPO            the absolute path of the repo
+//	TIDYGIT_DEFAULT_BRANCH  the default branch, if detected
+//	TIDYGIT_BRANCH          the affected branch, if any
+//	TIDYGIT_PR              the number of the branch's PR, if any
+//	TIDYGIT_WORKTREE        the affected worktree path, if any
+//
+// Commands of a hook point run in order and stop at the first failure.
+type Hooks struct {
+	BeforeRepo []string `toml:"before_repo"`
+	// AfterRepo only runs for a repo whose before_repo hooks ran, not one
+	// skipped at a setup prompt.
+	AfterRepo []string `toml:"after_repo"`
+	// BeforeDeleteBranch can veto the deletion by exiting non-zero.
+	BeforeDeleteBranch  []string `toml:"before_delete_branch"`
+	AfterRemoveWorktree []string `toml:"after_remove_worktree"`
+}
+
+// merge returns h with the commands of over appended at every hook point.
+func (h Hooks) merge(over Hooks) Hooks {
+	return Hooks{
+		BeforeRepo:          append(h.BeforeRepo[:len(h.BeforeRepo):len(h.BeforeRepo)], over.BeforeRepo...),
+		AfterRepo:           append(h.AfterRepo[:len(h.AfterRepo):len(h.AfterRepo)], over.AfterRepo...),
+		BeforeDeleteBranch:  append(h.BeforeDeleteBranch[:len(h.BeforeDeleteBranch):len(h.BeforeDeleteBranch)], over.BeforeDeleteBranch...),
+		AfterRemoveWorktree: append(h.AfterRemoveWorktree[:len(h.AfterRemoveWorktree):len(h.AfterRemoveWorktree)], over.AfterRemoveWorktree...),
+	}
+}
+
+// Empty reports whether no hook command is set.
+func (h Hooks) Empty() bool {
+	return len(h.BeforeRepo)+len(h.AfterRepo)+len(h.BeforeDeleteBranch)+len(h.AfterRemoveWorktree) == 0
+}
+
+// hookTarget is what a hook is run for.
+type hookTarget struct {
+	branch   string
+	worktree string
+	pr       *PR
+}
+
+// hook runs the commands of the hook point named point and returns the
+// first failure.
+func (r *run) hook(ctx context.Context, point string, cmds []string, t hookTarget) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	env := append(os.Environ(),
+		"TIDYGIT_HOOK="+point,
+		"TIDYGIT_REPO="+r.dir,
+		"TIDYGIT_DEFAULT_BRANCH="+r.defaultBranch,
+		"TIDYGIT_BRANCH="+t.branch,
+		"TIDYGIT_WORKTREE="+t.worktree,
+	)
+	pr := ""
+	if t.pr != nil {
+		pr = strconv.Itoa(t.pr.Number)
+	}
+	env = append(env, "TIDYGIT_PR="+pr)
+
+	for _, c := range cmds {
+		if err := r.runHook(ctx, point, c, env, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook runs a single hook command under the hooks timeout.
+func (r *run) runHook(ctx context.Context, point, c string, env []string, t hookTarget) error {
+	ctx, cancel := r.opts.withTimeout(ctx, "hooks")
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c)
+	cmd.Dir = r.dir
+	cmd.Env = env
+	cmd.WaitDelay = time.Second
+	r.emit(Event{Type: EventHook, Step: point, Branch: t.branch, Worktree: t.worktree, Message: c})
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%q: %w", c, r.opts.stepErr(ctx, "hooks", err))
+	}
+	return &hookError{command: c, output: strings.TrimSpace(string(out)), err: err}
+}
+
+// hookError is a failed hook command.
+type hookError struct {
+	command string
+	output  string
+	err     error
+}
+
+func (e *hookError) Error() string {
+	if e.output != "" {
+		return fmt.Sprintf("%q: %s: %v", e.command, e.output, e.err)
+	}
+	return fmt.Sprintf("%q: %v", e.command, e.err)
+}
+
+func (e *hookError) Unwrap() error {
+	return e.err
+}
+
+// reason is the hook's own explanation of the failure: its output, or the
+// exit status if it printed nothing.
+func (e *hookError) reason() string {
+	if e.output != "" {
+		return e.output
+	}
+	return e.err.Error()
+}
+
+// vetoed runs the before_delete_branch hook for branch and reports whether
+// it refused the deletion.
+func (r *run) vetoed(ctx context.Context, branch string) bool {
+	err := r.hook(ctx, "before_delete_branch", r.opts.Hooks.BeforeDeleteBranch, hookTarget{branch: branch, pr: prFor(r.prs, branch)})
+	if err == nil {
+		return false
+	}
+	var hookErr *hookError
+	var exit *exec.ExitError
+	if !errors.As(err, &hookErr) || !errors.As(err, &exit) {
+		// The hook could not run at all, so nothing vetoed the deletion
+		// on purpose, but deleting without it would skip its checks.
+		r.addErr("hook", "running before_delete_branch hook for "+branch, err)
+		return true
+	}
+	r.emit(Event{Type: EventVetoed, Branch: branch, Message: hookErr.reason()})
+	return true
+}
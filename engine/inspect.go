@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inspectCommits is the number of recent commits included in BranchDetails.
+const inspectCommits = 5
+
+// Commit is a single commit summary.
+type Commit struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// BranchDetails describes a branch well enough to decide whether it is safe
+// to delete.
+type BranchDetails struct {
+	Branch string `json:"branch"`
+	// Base is the remote-tracking default branch compared against, e.g.
+	// origin/main. Empty if the default branch is unknown.
+	Base       string `json:"base,omitempty"`
+	AheadBase  int    `json:"ahead_base"`
+	BehindBase int    `json:"behind_base"`
+
+	// Upstream is the configured upstream, e.g. origin/feature. Empty if the
+	// branch has no upstream.
+	Upstream       string `json:"upstream,omitempty"`
+	UpstreamGone   bool   `json:"upstream_gone,omitempty"`
+	AheadUpstream  int    `json:"ahead_upstream"`
+	BehindUpstream int    `json:"behind_upstream"`
+
+	// Commits are the most recent commits on the branch, newest first.
+	Commits []Commit `json:"commits"`
+	// Unpushed are commits not on any remote-tracking branch.
+	Unpushed []Commit `json:"unpushed"`
+	// DiffStat is `git diff --stat` of the branch against Base.
+	DiffStat string `json:"diffstat,omitempty"`
+}
+
+// InspectBranch gathers BranchDetails for branch in the repo at repoPath.
+// base is the remote-tracking default branch, e.g. origin/main. It may be
+// empty, in which case base comparisons are skipped.
+func InspectBranch(ctx context.Context, repoPath, branch, base string) (BranchDetails, error) {
+	d := BranchDetails{Branch: branch}
+	// A tag or a path at the repo root may share the branch's name.
+	ref := "refs/heads/" + branch
+
+	commits, err := gitLog(ctx, repoPath, inspectCommits, ref)
+	if err != nil {
+		return d, err
+	}
+	d.Commits = commits
+
+	if d.Unpushed, err = gitLog(ctx, repoPath, 20, ref, "--not", "--remotes"); err != nil {
+		return d, err
+	}
+
+	if base != "" {
+		if ahead, behind, err := gitAheadBehind(ctx, repoPath, base, ref); err == nil {
+			d.Base = base
+			d.AheadBase, d.BehindBase = ahead, behind
+			d.DiffStat, _ = gitDiffStat(ctx, repoPath, base, ref)
+		}
+	}
+
+	upstream, gone, err := gitUpstream(ctx, repoPath, branch)
+	if err != nil {
+		return d, err
+	}
+	d.Upstream, d.UpstreamGone = upstream, gone
+	if upstream != "" && !gone {
+		if ahead, behind, err := gitAheadBehind(ctx, repoPath, upstream, ref); err == nil {
+			d.AheadUpstream, d.BehindUpstream = ahead, behind
+		}
+	}
+
+	return d, nil
+}
+
+// gitLog returns up to n commits of the revisions in args, which are never
+// read as paths.
+func gitLog(ctx context.Context, dir string, n int, args ...string) ([]Commit, error) {
+	cmdArgs := append([]string{"log", fmt.Sprintf("-n%d", n), "--format=%h%x00%an%x00%ct%x00%s"}, args...)
+	cmdArgs = append(cmdArgs, "--")
+	out, err := gitCmd(ctx, dir, cmdArgs...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("reading log: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		sec, _ := strconv.ParseInt(fields[2], 10, 64)
+		commits = append(commits, Commit{
+			SHA:     fields[0],
+			Author:  fields[1],
+			Date:    time.Unix(sec, 0),
+			Subject: fields[3],
+		})
+	}
+	return commits, nil
+}
+
+// gitAheadBehind returns how many commits branch is ahead of and behind base.
+func gitAheadBehind(ctx context.Context, dir, base, branch string) (ahead, behind int, err error) {
+	out, err := gitCmd(ctx, dir, "rev-list", "--left-right", "--count", base+"..."+branch, "--").CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("comparing %s with %s: %s: %w", branch, base, strings.TrimSpace(string(out)), err)
+	}
+	left, right, _ := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	behind, _ = strconv.Atoi(left)
+	ahead, _ = strconv.Atoi(right)
+	return ahead, behind, nil
+}
+
+// gitUpstream returns the upstream of branch and whether it no longer exists
+// on the remote.
+func gitUpstream(ctx context.Context, dir, branch string) (upstream string, gone bool, err error) {
+	out, err := gitCmd(ctx, dir, "for-each-ref", "--format=%(upstream:short)%00%(upstream:track)", "refs/heads/"+branch).CombinedOutput()
+	if err != nil {
+		return "", false, fmt.Errorf("reading upstream of %s: %s: %w", branch, strings.TrimSpace(string(out)), err)
+	}
+	upstream, track, _ := strings.Cut(strings.TrimSpace(string(out)), "\x00")
+	return upstream, track == "[gone]", nil
+}
+
+func gitDiffStat(ctx context.Context, dir, base, branch string) (string, error) {
+	out, err := gitCmd(ctx, dir, "diff", "--stat=80", base+"..."+branch, "--").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("diffing %s against %s: %s: %w", branch, base, strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspectBranchNameClash(t *testing.T) {
+	dir := testRepo(t)
+	// A directory and a tag named like the branch must not be read instead
+	// of it.
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", "docs")
+	git(t, dir, "commit", "-q", "-m", "add docs")
+	git(t, dir, "push", "-q", "origin", "main")
+	git(t, dir, "tag", "docs", "HEAD~1")
+	git(t, dir, "switch", "-q", "-c", "docs")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "docs work")
+	git(t, dir, "switch", "-q", "main")
+
+	d, err := InspectBranch(context.Background(), dir, "docs", "origin/main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Commits) != 3 || d.Commits[0].Subject != "docs work" {
+		t.Errorf("Commits = %+v, want 3 ending with docs work", d.Commits)
+	}
+	if len(d.Unpushed) != 1 || d.AheadBase != 1 || d.BehindBase != 0 {
+		t.Errorf("Unpushed = %d, ahead/behind = %d/%d, want 1, 1/0", len(d.Unpushed), d.AheadBase, d.BehindBase)
+	}
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepKey is the git config variable, under branch.<name>, that remembers a
+// decision to keep a branch.
+const keepKey = "tidygitKeep"
+
+// keep is a remembered decision to keep a branch, stored as "tip:<sha>"
+// until the branch moves or "until:<unix time>" until a date.
+type keep struct {
+	tip   string
+	until time.Time
+}
+
+func parseKeep(value string) (keep, bool) {
+	kind, arg, _ := strings.Cut(value, ":")
+	switch kind {
+	case "tip":
+		return keep{tip: arg}, arg != ""
+	case "until":
+		sec, err := strconv.ParseInt(arg, 10, 64)
+		return keep{until: time.Unix(sec, 0)}, err == nil
+	}
+	return keep{}, false
+}
+
+func (k keep) String() string {
+	if k.tip != "" {
+		return "tip:" + k.tip
+	}
+	return fmt.Sprintf("until:%d", k.until.Unix())
+}
+
+// active reports whether the keep still applies to a branch at sha.
+func (k keep) active(sha string) bool {
+	if k.tip != "" {
+		return k.tip == sha
+	}
+	return time.Now().Before(k.until)
+}
+
+// describe returns how long the keep lasts, e.g. "until the branch changes".
+func (k keep) describe() string {
+	if k.tip != "" {
+		return "until the branch changes"
+	}
+	return "until " + k.until.Format("2006-01-02")
+}
+
+// gitKeeps returns the remembered keeps of every branch that has one.
+func gitKeeps(ctx context.Context, dir string) (map[string]keep, error) {
+	out, err := gitCmd(ctx, dir, "config", "--local", "--get-regexp", `^branch\..*\.`+strings.ToLower(keepKey)+`$`).CombinedOutput()
+	if err != nil {
+		// git config exits 1 when nothing matches.
+		if len(out) == 0 {
+			return map[string]keep{}, nil
+		}
+		return nil, fmt.Errorf("reading remembered keeps: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	keeps := make(map[string]keep)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		branch := strings.TrimSuffix(strings.TrimPrefix(key, "branch."), "."+strings.ToLower(keepKey))
+		if k, ok := parseKeep(value); ok {
+			keeps[branch] = k
+		}
+	}
+	return keeps, nil
+}
+
+func gitSetKeep(ctx context.Context, dir, branch string, k keep) error {
+	out, err := gitCmd(ctx, dir, "config", "--local", "branch."+branch+"."+keepKey, k.String()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("remembering keep for %s: %s: %w", branch, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
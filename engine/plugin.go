@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PluginPrefix is the executable name prefix of plugin steps: the step
+// "lockfile" runs tidygit-step-lockfile.
+const PluginPrefix = "tidygit-step-"
+
+// Plugin is an external pipeline step. It runs after the built-in Steps
+// with a PluginInput as JSON on stdin and writes a PluginOutput as JSON to
+// stdout.
+type Plugin struct {
+	// Name is the step name, as used by Options.Only and Options.Skip.
+	Name string
+	// Path is the executable.
+	Path string
+}
+
+// ResolvePlugin finds the plugin for a config entry. An entry containing a
+// "/" is the path of the executable, with "~/" for the home directory, and
+// its step name is the file name without PluginPrefix. Any other entry is a
+// step name whose executable, PluginPrefix+name, is looked up on PATH.
+func ResolvePlugin(entry string) (Plugin, error) {
+	if !strings.Contains(entry, "/") {
+		path, err := exec.LookPath(PluginPrefix + entry)
+		if err != nil {
+			return Plugin{}, fmt.Errorf("plugin %q: %w", entry, err)
+		}
+		return Plugin{Name: entry, Path: path}, nil
+	}
+
+	path := entry
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Plugin{}, fmt.Errorf("plugin %q: %w", entry, err)
+		}
+		path = filepath.Join(home, rest)
+	}
+	if _, err := exec.LookPath(path); err != nil {
+		return Plugin{}, fmt.Errorf("plugin %q: %w", entry, err)
+	}
+	name := strings.TrimPrefix(filepath.Base(path), PluginPrefix)
+	return Plugin{Name: name, Path: path}, nil
+}
+
+// PluginInput describes the repo to a plugin step.
+type PluginInput struct {
+	Step          string           `json:"step"`
+	Repo          string           `json:"repo"`
+	DefaultBranch string           `json:"default_branch,omitempty"`
+	CurrentBranch string           `json:"current_branch,omitempty"`
+	Remote        string           `json:"remote"`
+	Auto          bool             `json:"auto"`
+	Offline       bool             `json:"offline"`
+	Branches      []string         `json:"branches"`
+	Worktrees     []PluginWorktree `json:"worktrees"`
+	// Result is the report of the run so far.
+	Result Result `json:"result"`
+}
+
+// PluginWorktree is a worktree in PluginInput.
+type PluginWorktree struct {
+	Path   string `json:"path"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// PluginOutput is what a plugin step reports. Every field is optional.
+type PluginOutput struct {
+	// Actions describe what the step did, one line each.
+	Actions []string `json:"actions"`
+	// Counts are totals shown in the summary, keyed by a short label such
+	// as "cache dirs removed".
+	Counts map[string]int `json:"counts"`
+	// Errors are failures recorded against the step.
+	Errors []string `json:"errors"`
+}
+
+// PluginResult records what a plugin step did during a run.
+type PluginResult struct {
+	Step    string         `json:"step"`
+	Actions []string       `json:"actions"`
+	Counts  map[string]int `json:"counts,omitempty"`
+}
+
+// runPlugin returns the pipeline step function for p.
+func (r *run) runPlugin(p Plugin) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var out PluginOutput
+		err := r.step(ctx, p.Name, func(ctx context.Context) error {
+			var err error
+			out, err = r.callPlugin(ctx, p)
+			return err
+		})
+		if err != nil {
+			r.addErr(p.Name, "running plugin "+p.Name, err)
+			return nil
+		}
+
+		for _, a := range out.Actions {
+			r.emit(Event{Type: EventPluginAction, Step: p.Name, Message: a})
+		}
+		for _, e := range out.Errors {
+			r.addErr(p.Name, p.Name, errors.New(e))
+		}
+		actions := out.Actions
+		if actions == nil {
+			actions = []string{}
+		}
+		r.result.Plugins = append(r.result.Plugins, PluginResult{Step: p.Name, Actions: actions, Counts: out.Counts})
+		return nil
+	}
+}
+
+// callPlugin runs p with the current repo state on stdin and decodes its
+// output.
+func (r *run) callPlugin(ctx context.Context, p Plugin) (PluginOutput, error) {
+	in := PluginInput{
+		Step:          p.Name,
+		Repo:          r.dir,
+		DefaultBranch: r.defaultBranch,
+		Remote:        r.opts.Remote,
+		Auto:          r.opts.Auto,
+		Offline:       r.opts.Offline,
+		Branches:      []string{},
+		Worktrees:     []PluginWorktree{},
+		Result:        r.result,
+	}
+	// The state may be partial, so listing failures are not fatal.
+	in.CurrentBranch, _ = gitCurrentBranch(ctx, r.dir)
+	if branches, err := gitListBranches(ctx, r.dir, "__none__"); err == nil {
+		in.Branches = branches
+	}
+	if worktrees, err := gitListWorktrees(ctx, r.dir); err == nil {
+		for _, wt := range worktrees {
+			in.Worktrees = append(in.Worktrees, PluginWorktree{Path: wt.Path, Branch: wt.Branch})
+		}
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		return PluginOutput{}, fmt.Errorf("encoding input: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Dir = r.dir
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		return PluginOutput{}, fmt.Errorf("%s: %s: %w", p.Path, strings.TrimSpace(stderr.String()), err)
+	}
+
+	var out PluginOutput
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return out, nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return PluginOutput{}, fmt.Errorf("decoding output of %s: %w", p.Path, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutoPolicy selects which branches auto mode deletes, along with their
+// worktrees. The zero value deletes branches with merged PRs only.
+// Protected and kept branches, branches with unpushed work or an open PR and
+// worktrees with uncommitted changes are never deleted.
+type AutoPolicy struct {
+	// ClosedOlderThan, if set, also deletes branches whose PR was closed
+	// without merging at least this long ago.
+	ClosedOlderThan time.Duration
+	// MergedLocally also deletes branches that are contained in or were
+	// squash-merged into the default branch.
+	MergedLocally bool
+	// UpstreamGone also deletes branches whose upstream was deleted.
+	UpstreamGone bool
+	// Stale also deletes branches classified as stale, which requires
+	// Options.OlderThan.
+	Stale bool
+}
+
+// Deletes reports whether auto mode deletes a branch classified as c whose
+// PR, if any, is pr.
+func (p AutoPolicy) Deletes(c Classification, pr *PR) bool {
+	if !c.Offered() {
+		return false
+	}
+	switch c.Status {
+	case StatusProtected, StatusUnpushed, StatusDirty, StatusOpenPR:
+		return false
+	case StatusMergedPR:
+		return true
+	case StatusAncestor, StatusSquashMerged:
+		if p.MergedLocally {
+			return true
+		}
+	case StatusUpstreamGone:
+		if p.UpstreamGone {
+			return true
+		}
+	}
+	if p.Stale && c.Stale {
+		return true
+	}
+	return p.ClosedOlderThan > 0 && pr != nil && pr.State == "CLOSED" &&
+		!pr.ClosedAt.IsZero() && time.Since(pr.ClosedAt) >= p.ClosedOlderThan
+}
+
+// String formats p in the syntax accepted by ParseAutoPolicy.
+func (p AutoPolicy) String() string {
+	rules := []string{"merged"}
+	if p.ClosedOlderThan > 0 {
+		rules = append(rules, "closed:"+FormatAge(p.ClosedOlderThan))
+	}
+	if p.MergedLocally {
+		rules = append(rules, "merged-locally")
+	}
+	if p.UpstreamGone {
+		rules = append(rules, "upstream-gone")
+	}
+	if p.Stale {
+		rules = append(rules, "stale")
+	}
+	return strings.Join(rules, ",")
+}
+
+// ParseAutoPolicy parses a comma-separated list of rules: "merged" (always
+// on), "closed:<age>" such as "closed:30d", "merged-locally",
+// "upstream-gone" and "stale".
+func ParseAutoPolicy(s string) (AutoPolicy, error) {
+	var p AutoPolicy
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		switch {
+		case rule == "" || rule == "merged":
+		case rule == "merged-locally":
+			p.MergedLocally = true
+		case rule == "upstream-gone":
+			p.UpstreamGone = true
+		case rule == "stale":
+			p.Stale = true
+		case strings.HasPrefix(rule, "closed:"):
+			age, err := ParseAge(strings.TrimPrefix(rule, "closed:"))
+			if err != nil {
+				return p, fmt.Errorf("auto policy rule %q: %w", rule, err)
+			}
+			p.ClosedOlderThan = age
+		default:
+			return p, fmt.Errorf("unknown auto policy rule %q", rule)
+		}
+	}
+	return p, nil
+}
+
+// ParseAge parses an age such as "90d", "2w" or "1y", or any duration
+// accepted by time.ParseDuration.
+func ParseAge(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+	if s != "" {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// FormatAge formats d in the syntax accepted by ParseAge, in whole days
+// where possible.
+func FormatAge(d time.Duration) string {
+	day := 24 * time.Hour
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
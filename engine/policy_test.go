@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoPolicyDeletes(t *testing.T) {
+	closedAt := time.Now().Add(-40 * 24 * time.Hour)
+	closed := &PR{Number: 1, State: "CLOSED", ClosedAt: closedAt}
+	open := &PR{Number: 2, State: "OPEN"}
+	all := AutoPolicy{ClosedOlderThan: 30 * 24 * time.Hour, MergedLocally: true, UpstreamGone: true, Stale: true}
+
+	tests := []struct {
+		name   string
+		policy AutoPolicy
+		class  Classification
+		pr     *PR
+		want   bool
+	}{
+		{"merged PR", AutoPolicy{}, Classification{Status: StatusMergedPR}, nil, true},
+		{"merged PR kept", AutoPolicy{}, Classification{Status: StatusMergedPR, Kept: "until changed"}, nil, false},
+		{"ancestor by default", AutoPolicy{}, Classification{Status: StatusAncestor}, nil, false},
+		{"ancestor merged locally", AutoPolicy{MergedLocally: true}, Classification{Status: StatusAncestor}, nil, true},
+		{"squash merged locally", AutoPolicy{MergedLocally: true}, Classification{Status: StatusSquashMerged}, nil, true},
+		{"upstream gone by default", AutoPolicy{}, Classification{Status: StatusUpstreamGone}, nil, false},
+		{"upstream gone", AutoPolicy{UpstreamGone: true}, Classification{Status: StatusUpstreamGone}, nil, true},
+		{"stale unknown", AutoPolicy{Stale: true}, Classification{Status: StatusUnknown, Stale: true}, nil, true},
+		{"stale unknown by default", AutoPolicy{}, Classification{Status: StatusUnknown, Stale: true}, nil, false},
+		{"closed long ago", AutoPolicy{ClosedOlderThan: 30 * 24 * time.Hour}, Classification{Status: StatusUnknown}, closed, true},
+		{"closed recently", AutoPolicy{ClosedOlderThan: 60 * 24 * time.Hour}, Classification{Status: StatusUnknown}, closed, false},
+		{"new branch", all, Classification{Status: StatusNew}, nil, false},
+		{"protected", all, Classification{Status: StatusProtected, Stale: true}, nil, false},
+		{"unpushed", all, Classification{Status: StatusUnpushed, Stale: true}, closed, false},
+		{"dirty worktree", all, Classification{Status: StatusDirty, Stale: true}, nil, false},
+		{"open PR", all, Classification{Status: StatusOpenPR, Stale: true}, open, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Deletes(tt.class, tt.pr); got != tt.want {
+				t.Errorf("Deletes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAutoPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    AutoPolicy
+		wantErr bool
+	}{
+		{in: "", want: AutoPolicy{}},
+		{in: "merged", want: AutoPolicy{}},
+		{in: "closed:30d, merged-locally", want: AutoPolicy{ClosedOlderThan: 30 * 24 * time.Hour, MergedLocally: true}},
+		{in: "upstream-gone,stale", want: AutoPolicy{UpstreamGone: true, Stale: true}},
+		{in: "closed:soon", wantErr: true},
+		{in: "everything", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAutoPolicy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAutoPolicy(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseAutoPolicy(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAutoPolicyStringRoundTrip(t *testing.T) {
+	p := AutoPolicy{ClosedOlderThan: 14 * 24 * time.Hour, MergedLocally: true, UpstreamGone: true, Stale: true}
+	got, err := ParseAutoPolicy(p.String())
+	if err != nil || got != p {
+		t.Errorf("ParseAutoPolicy(%q) = %+v, %v, want %+v", p.String(), got, err, p)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "90d", want: 90 * day},
+		{in: "2w", want: 14 * day},
+		{in: "1y", want: 365 * day},
+		{in: "36h", want: 36 * time.Hour},
+		{in: "0d", want: 0},
+		{in: "", wantErr: true},
+		{in: "d", wantErr: true},
+		{in: "-3d", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAge(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// prCache is the PR data of a repo as last fetched, kept for offline runs.
+type prCache struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	PRs       []PR      `json:"prs"`
+}
+
+// prCachePath returns the path of the PR cache of the repo at dir. It lives
+// in the common git directory, so it is shared by worktrees and never
+// committed.
+func prCachePath(ctx context.Context, dir string) (string, error) {
+	out, err := gitCmd(ctx, dir, "rev-parse", "--git-common-dir").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("finding git directory: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	gitDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return filepath.Join(gitDir, "tidygit", "prs.json"), nil
+}
+
+// savePRCache stores prs as the PR data of the repo at dir.
+func savePRCache(ctx context.Context, dir string, prs map[string]PR) error {
+	path, err := prCachePath(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	c := prCache{FetchedAt: time.Now(), PRs: make([]PR, 0, len(prs))}
+	for _, pr := range prs {
+		c.PRs = append(c.PRs, pr)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encoding PR cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("writing PR cache: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing PR cache: %w", err)
+	}
+	return nil
+}
+
+// loadPRCache returns the cached PR data of the repo at dir and when it was
+// fetched. A missing cache is an empty map and a zero time.
+func loadPRCache(ctx context.Context, dir string) (map[string]PR, time.Time, error) {
+	path, err := prCachePath(ctx, dir)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]PR{}, time.Time{}, nil
+	} else if err != nil {
+		return nil, time.Time{}, fmt.Errorf("reading PR cache: %w", err)
+	}
+
+	var c prCache
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing PR cache %s: %w", path, err)
+	}
+	prs := make(map[string]PR, len(c.PRs))
+	for _, pr := range c.PRs {
+		prs[pr.Branch] = pr
+	}
+	return prs, c.FetchedAt, nil
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Protection matches the branches and worktrees that are never offered for
+// deletion. The zero value protects nothing beyond the default branch.
+type Protection struct {
+	branches  []protectPattern
+	worktrees []protectPattern
+}
+
+type protectPattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// NewProtection compiles protection patterns. A pattern starting with "re:"
+// is a regular expression matched against the whole branch name. A pattern
+// starting with "/" or "~/" matches worktree paths, and a trailing slash
+// matches everything under the directory. Any other pattern is a glob
+// matched against branch names, where "*" matches within one path segment
+// and "**" matches across segments, e.g. "release/*" or "hotfix/**".
+func NewProtection(patterns []string) (Protection, error) {
+	var p Protection
+	for _, pattern := range patterns {
+		switch {
+		case strings.HasPrefix(pattern, "re:"):
+			re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, "re:") + ")$")
+			if err != nil {
+				return p, fmt.Errorf("protected pattern %q: %w", pattern, err)
+			}
+			p.branches = append(p.branches, protectPattern{source: pattern, re: re})
+		case strings.HasPrefix(pattern, "/") || strings.HasPrefix(pattern, "~/"):
+			path := pattern
+			if rest, ok := strings.CutPrefix(path, "~/"); ok {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return p, fmt.Errorf("protected pattern %q: %w", pattern, err)
+				}
+				path = filepath.Join(home, rest)
+				if strings.HasSuffix(pattern, "/") {
+					path += "/"
+				}
+			}
+			if strings.HasSuffix(path, "/") {
+				path += "**"
+			}
+			p.worktrees = append(p.worktrees, protectPattern{source: pattern, re: globRegexp(path)})
+		default:
+			p.branches = append(p.branches, protectPattern{source: pattern, re: globRegexp(pattern)})
+		}
+	}
+	return p, nil
+}
+
+// globRegexp converts a glob to an anchored regular expression.
+func globRegexp(glob string) *regexp.Regexp {
+	runes := []rune(glob)
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Branch returns the pattern protecting branch, if any.
+func (p Protection) Branch(branch string) (string, bool) {
+	return match(p.branches, branch)
+}
+
+// Worktree returns the pattern protecting the worktree at path, if any.
+func (p Protection) Worktree(path string) (string, bool) {
+	return match(p.worktrees, filepath.Clean(path))
+}
+
+func match(patterns []protectPattern, s string) (string, bool) {
+	for _, pattern := range patterns {
+		if pattern.re.MatchString(s) {
+			return pattern.source, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewProtection(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	p, err := NewProtection([]string{
+		"develop",
+		"release/*",
+		"hotfix/**",
+		`re:v\d+\.x`,
+		"/srv/wt/keep/",
+		"~/wt/pinned",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branches := []struct {
+		branch string
+		want   string
+	}{
+		{"develop", "develop"},
+		{"develop2", ""},
+		{"feature/develop", ""},
+		{"release/1.0", "release/*"},
+		{"release/1.0/rc", ""},
+		{"release", ""},
+		{"hotfix/a", "hotfix/**"},
+		{"hotfix/a/b", "hotfix/**"},
+		{"v2.x", `re:v\d+\.x`},
+		{"v2.x-old", ""},
+		{"xv2.x", ""},
+	}
+	for _, tt := range branches {
+		got, ok := p.Branch(tt.branch)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Branch(%q) = %q, %v, want %q", tt.branch, got, ok, tt.want)
+		}
+	}
+
+	worktrees := []struct {
+		path string
+		want string
+	}{
+		{"/srv/wt/keep/a", "/srv/wt/keep/"},
+		{"/srv/wt/keep/a/b/", "/srv/wt/keep/"},
+		{"/srv/wt/keeper", ""},
+		{filepath.Join(home, "wt/pinned"), "~/wt/pinned"},
+		{filepath.Join(home, "wt/pinned/sub"), ""},
+		{"develop", ""},
+	}
+	for _, tt := range worktrees {
+		got, ok := p.Worktree(tt.path)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Worktree(%q) = %q, %v, want %q", tt.path, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNewProtectionInvalidRegexp(t *testing.T) {
+	if _, err := NewProtection([]string{"re:("}); err == nil {
+		t.Error("NewProtection(re:() succeeded, want error")
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob, s string
+		want    bool
+	}{
+		{"a/*", "a/b", true},
+		{"a/*", "a/b/c", false},
+		{"a/**", "a/b/c", true},
+		{"a/?", "a/b", true},
+		{"a/?", "a//", false},
+		{"a.b", "axb", false},
+		{"a+b", "a+b", true},
+		{"*", "", true},
+	}
+	for _, tt := range tests {
+		if got := globRegexp(tt.glob).MatchString(tt.s); got != tt.want {
+			t.Errorf("glob %q matching %q = %v, want %v", tt.glob, tt.s, got, tt.want)
+		}
+	}
+}
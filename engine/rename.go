@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// oldDefaultBranches are the names a default branch usually had before it
+// was renamed.
+var oldDefaultBranches = []string{"master", "main", "trunk"}
+
+// renamedFrom returns the local branch left behind when the remote renamed
+// its default branch: one of oldDefaultBranches that tracks a branch of the
+// same name the remote no longer has, while the default branch has no local
+// branch yet. It returns "" if there is none.
+func (r *run) renamedFrom(ctx context.Context) string {
+	if r.defaultBranch == "" || gitRefExists(ctx, r.dir, "refs/heads/"+r.defaultBranch) {
+		return ""
+	}
+	for _, old := range oldDefaultBranches {
+		if old == r.defaultBranch || !gitRefExists(ctx, r.dir, "refs/heads/"+old) {
+			continue
+		}
+		upstream, gone, err := gitUpstream(ctx, r.dir, old)
+		if err != nil || upstream != r.opts.Remote+"/"+old {
+			continue
+		}
+		if !gone {
+			// Only a fetch prunes the remote-tracking branch, so ask the
+			// remote unless offline.
+			if r.opts.Offline || r.remoteHasBranch(ctx, old) {
+				continue
+			}
+		}
+		return old
+	}
+	return ""
+}
+
+// remoteHasBranch asks the remote whether it still has branch. Failures
+// count as yes, so nothing is renamed on a guess.
+func (r *run) remoteHasBranch(ctx context.Context, branch string) bool {
+	ctx, cancel := r.opts.withTimeout(ctx, "default-branch")
+	defer cancel()
+	has, err := gitRemoteHasBranch(ctx, r.dir, r.opts.Remote, branch)
+	return err != nil || has
+}
+
+// followRename checks, after a fetch, that the remote still has the default
+// branch. A fetch prunes it when the remote renamed it while
+// refs/remotes/<remote>/HEAD still names it, so the default branch is then
+// detected again from the remote and the rename offered.
+func (r *run) followRename(ctx context.Context) error {
+	if r.defaultBranch == "" || gitRefExists(ctx, r.dir, "refs/remotes/"+r.opts.Remote+"/"+r.defaultBranch) {
+		return nil
+	}
+
+	old := r.defaultBranch
+	branch, _, err := r.opts.remoteDefaultBranch(ctx, r.dir)
+	if err != nil {
+		r.addErr("default-branch", "detecting renamed default branch", err)
+		return nil
+	}
+	if branch == old {
+		return nil
+	}
+	r.defaultBranch = branch
+	r.result.DefaultBranch = branch
+	current, _ := gitCurrentBranch(ctx, r.dir)
+	r.onDefaultBranch = current == branch
+
+	if gitRefExists(ctx, r.dir, "refs/heads/"+branch) || !gitRefExists(ctx, r.dir, "refs/heads/"+old) {
+		return r.setHead(ctx)
+	}
+	return r.renameDefault(ctx, old)
+}
+
+// renameDefault offers to rename old, left behind by a rename of the default
+// branch, to the default branch, make it track the remote's default branch
+// and point refs/remotes/<remote>/HEAD at it.
+func (r *run) renameDefault(ctx context.Context, old string) error {
+	remote := r.opts.Remote
+	confirmed, err := r.confirm(ctx, Prompt{
+		Kind:          PromptRenameDefault,
+		Title:         fmt.Sprintf("Rename %s to %s, the new default branch of %s?", old, r.defaultBranch, remote),
+		Default:       true,
+		Repo:          r.dir,
+		DefaultBranch: r.defaultBranch,
+		Remote:        remote,
+		Branch:        old,
+	}, true)
+	if errors.Is(err, errStopRepo) {
+		return err
+	} else if err != nil {
+		r.addErr("prompt", "prompting for rename of "+old, err)
+		return nil
+	} else if !confirmed {
+		r.skip(Event{Step: "rename", Branch: old})
+		return nil
+	}
+
+	if err := gitRenameBranch(ctx, r.dir, old, r.defaultBranch, remote); err != nil {
+		r.addErr("rename", "renaming "+old, err)
+		return nil
+	}
+	r.emit(Event{Type: EventRenamed, Branch: r.defaultBranch, Message: old})
+	current, _ := gitCurrentBranch(ctx, r.dir)
+	r.onDefaultBranch = current == r.defaultBranch
+
+	if head, err := gitLocalDefaultBranch(ctx, r.dir, remote); err == nil && head == r.defaultBranch {
+		return nil
+	}
+	if err := gitSetRemoteHEAD(ctx, r.dir, remote, r.defaultBranch); err != nil {
+		r.addErr("set-head", "setting "+remote+"/HEAD", err)
+	} else {
+		r.emit(Event{Type: EventHeadSet, Branch: r.defaultBranch, Message: remote})
+	}
+	return nil
+}
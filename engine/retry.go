@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// transientMarkers are fragments of git, ssh, curl and gh output that mean
+// a failure was caused by the network or the server and may pass.
+var transientMarkers = []string{
+	"could not resolve host",
+	"no such host",
+	"temporary failure in name resolution",
+	"name or service not known",
+	"connection timed out",
+	"operation timed out",
+	"i/o timeout",
+	"connection reset",
+	"connection refused",
+	"connection closed by",
+	"error connecting to",
+	"network is unreachable",
+	"tls handshake timeout",
+	"ssl_connect",
+	"gnutls",
+	"early eof",
+	"unexpected disconnect",
+	"the remote end hung up unexpectedly",
+	"rpc failed",
+	"kex_exchange_identification",
+	"returned error: 429",
+	"returned error: 500",
+	"returned error: 502",
+	"returned error: 503",
+	"returned error: 504",
+	"http 429",
+	"http 500",
+	"http 502",
+	"http 503",
+	"http 504",
+	"rate limit",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+}
+
+// transientError marks a failure that is worth retrying.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// transient returns err marked as transient if the command output out
+// shows a network or server failure, and err unchanged otherwise.
+func transient(out []byte, err error) error {
+	lower := strings.ToLower(string(out))
+	for _, marker := range transientMarkers {
+		if strings.Contains(lower, marker) {
+			return &transientError{err: err}
+		}
+	}
+	return err
+}
+
+// isTransient reports whether err was marked by transient.
+func isTransient(err error) bool {
+	var t *transientError
+	return errors.As(err, &t)
+}
+
+// retryBase and retryCap bound the backoff between attempts.
+const (
+	retryBase = time.Second
+	retryCap  = 30 * time.Second
+)
+
+// retryStep is step for a step whose commands are retried. The step's
+// timeout applies to each attempt, so backoff does not eat into it.
+func (r *run) retryStep(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	r.emit(Event{Type: EventStepStarted, Step: name})
+	err := r.retry(ctx, name, fn)
+	r.emit(Event{Type: EventStepFinished, Step: name})
+	return err
+}
+
+// retry runs fn under the timeout of step, and runs it again after an
+// exponential backoff while it fails with a transient error or times out, up
+// to Options.Retries more times. It gives up early when ctx is done. Each
+// retry is reported as EventRetrying with the number of failed attempts in
+// Count.
+func (r *run) retry(ctx context.Context, step string, fn func(ctx context.Context) error) error {
+	delay := retryBase
+	for attempt := 1; ; attempt++ {
+		err := r.attempt(ctx, step, fn)
+		if err == nil || !isTransient(err) || attempt > r.opts.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		reason, _, _ := strings.Cut(err.Error(), "\n")
+		// Jitter keeps repos that failed together from retrying together.
+		wait := delay/2 + rand.N(delay/2+1)
+		r.emit(Event{
+			Type:    EventRetrying,
+			Step:    step,
+			Count:   attempt,
+			Message: fmt.Sprintf("retrying in %s: %s", wait.Round(100*time.Millisecond), reason),
+		})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(2*delay, retryCap)
+	}
+}
+
+// attempt runs fn once under the timeout of step. Running out of time is
+// transient, since a slow network or server may recover.
+func (r *run) attempt(ctx context.Context, step string, fn func(ctx context.Context) error) error {
+	attemptCtx, cancel := r.opts.withTimeout(ctx, step)
+	defer cancel()
+	err := r.opts.stepErr(attemptCtx, step, fn(attemptCtx))
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return &transientError{err: err}
+	}
+	return err
+}
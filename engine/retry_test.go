@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTransient(t *testing.T) {
+	base := errors.New("exit status 128")
+	tests := []struct {
+		out  string
+		want bool
+	}{
+		{"fatal: unable to access 'https://github.com/o/r/': Could not resolve host: github.com", true},
+		{"ssh: connect to host github.com port 22: Connection timed out", true},
+		{"error: RPC failed; curl 56 GnuTLS recv error (-9)", true},
+		{"fatal: unable to access '...': The requested URL returned error: 503", true},
+		{"HTTP 502: Bad Gateway (https://api.github.com/graphql)", true},
+		{"API rate limit exceeded for user", true},
+		{"error connecting to api.github.com", true},
+		{"git@github.com: Permission denied (publickey).", false},
+		{"fatal: Authentication failed for 'https://github.com/o/r/'", false},
+		{"fatal: The requested URL returned error: 404", false},
+		{"CONFLICT (content): Merge conflict in main.go", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		err := transient([]byte(tt.out), base)
+		if got := isTransient(err); got != tt.want {
+			t.Errorf("transient(%q) = %v, want %v", tt.out, got, tt.want)
+		}
+		if !errors.Is(err, base) {
+			t.Errorf("transient(%q) lost the wrapped error", tt.out)
+		}
+	}
+}
+
+func TestIsTransientWrapped(t *testing.T) {
+	err := fmt.Errorf("fetching: %w", transient([]byte("early EOF"), errors.New("exit status 1")))
+	if !isTransient(err) {
+		t.Error("isTransient of a wrapped transient error = false, want true")
+	}
+}
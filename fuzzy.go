@@ -0,0 +1,23 @@
+package main
+
+import "strings"
+
+// fuzzyMatch reports whether every rune of pattern appears in s in order,
+// ignoring case, e.g. "fxlog" matches "fix/login". An empty pattern matches
+// everything.
+func fuzzyMatch(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	pr := []rune(strings.ToLower(pattern))
+	i := 0
+	for _, r := range strings.ToLower(s) {
+		if r == pr[i] {
+			i++
+			if i == len(pr) {
+				return true
+			}
+		}
+	}
+	return false
+}
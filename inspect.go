@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/kpurdon/tidygit/engine"
+)
+
+// maxDiffStatLines caps the diffstat shown by the branch inspector.
+const maxDiffStatLines = 8
+
+type branchDetailsMsg struct {
+	details engine.BranchDetails
+	err     error
+}
+
+// inspectCmd loads the branch inspector details for p in the background.
+func inspectCmd(p engine.Prompt) tea.Cmd {
+	return func() tea.Msg {
+		var base string
+		if p.DefaultBranch != "" {
+			base = p.Remote + "/" + p.DefaultBranch
+		}
+		d, err := engine.InspectBranch(context.Background(), p.Repo, p.Branch, base)
+		return branchDetailsMsg{details: d, err: err}
+	}
+}
+
+// detailsLines renders branch details for the prompt's inspector.
+func detailsLines(d engine.BranchDetails) []string {
+	sep := dimStyle.Render(" · ")
+	var lines []string
+
+	var tracking []string
+	if d.Base != "" {
+		tracking = append(tracking, fmt.Sprintf("%d ahead, %d behind %s", d.AheadBase, d.BehindBase, d.Base))
+	}
+	switch {
+	case d.Upstream == "":
+		tracking = append(tracking, warnStyle.Render("no upstream"))
+	case d.UpstreamGone:
+		tracking = append(tracking, warnStyle.Render("upstream "+d.Upstream+" gone"))
+	default:
+		tracking = append(tracking, fmt.Sprintf("%d ahead, %d behind %s", d.AheadUpstream, d.BehindUpstream, d.Upstream))
+	}
+	lines = append(lines, "    "+strings.Join(tracking, sep))
+
+	if len(d.Commits) > 0 {
+		last := d.Commits[0]
+		lines = append(lines, "    "+dimStyle.Render(fmt.Sprintf("last commit %s ago by %s", formatAge(last.Date), last.Author)))
+	}
+
+	if len(d.Unpushed) > 0 {
+		lines = append(lines, "    "+errStyle.Render(fmt.Sprintf("%d unpushed commit(s)", len(d.Unpushed))))
+	} else {
+		lines = append(lines, "    "+okStyle.Render("no unpushed commits"))
+	}
+
+	if len(d.Commits) > 0 {
+		lines = append(lines, "    "+sectionStyle.Render("Recent commits"))
+		for _, c := range d.Commits {
+			lines = append(lines, fmt.Sprintf("      %s %s %s",
+				prStyle.Render(c.SHA), c.Subject, dimStyle.Render("("+c.Author+", "+formatAge(c.Date)+")")))
+		}
+	}
+
+	if d.DiffStat != "" {
+		lines = append(lines, "    "+sectionStyle.Render("Diffstat vs "+d.Base))
+		stat := strings.Split(d.DiffStat, "\n")
+		if len(stat) > maxDiffStatLines {
+			// Keep the summary line at the end.
+			omitted := len(stat) - maxDiffStatLines
+			stat = append(stat[:maxDiffStatLines-1:maxDiffStatLines-1],
+				fmt.Sprintf(" ... %d more file(s)", omitted), stat[len(stat)-1])
+		}
+		for _, l := range stat {
+			lines = append(lines, "     "+dimStyle.Render(l))
+		}
+	}
+
+	return lines
+}
+
+// maxGroupLines caps the branches listed for a branch group prompt.
+const maxGroupLines = 10
+
+// groupLines lists the branches covered by a group prompt.
+func groupLines(members []engine.Prompt) []string {
+	var lines []string
+	for i, p := range members {
+		if i == maxGroupLines {
+			lines = append(lines, "    "+dimStyle.Render(fmt.Sprintf("... %d more", len(members)-i)))
+			break
+		}
+		line := "    " + itemStyle.Render(p.Branch)
+		if p.PR != nil {
+			line += fmt.Sprintf(" %s %s", prStyle.Render(fmt.Sprintf("#%d", p.PR.Number)), styledPRState(p.PR.State))
+		} else {
+			line += dimStyle.Render(" no PR")
+		}
+		if !p.LastCommit.IsZero() {
+			line += dimStyle.Render(" · " + formatAge(p.LastCommit) + " ago")
+		}
+		line += " " + statusBadge(p.Class.Status, 0)
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -1,26 +1,244 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/kpurdon/tidygit/engine"
 )
 
+const usage = "Usage: tidygit [--auto] [--auto-policy rules] [--older-than age] [--protect pattern]... [--plugin step]... [--only steps] [--skip steps] [--offline] [--timeout step=duration]... [--retries n] [--checklist] [--plain] [--non-interactive fail|auto] [--output text|json] [--events ndjson [--events-file path]] [all [dir] | why <branch>]\n"
+
+// cliOptions holds the parsed command-line flags.
+type cliOptions struct {
+	auto       bool
+	offline    bool
+	checklist  bool
+	plain      bool
+	output     string
+	eventsFmt  string
+	eventsFile string
+
+	// nonInteractive is what to do when prompts cannot be shown: "fail"
+	// exits immediately, "auto" falls back to --auto decisions.
+	nonInteractive string
+
+	// events receives engine events when --events is set.
+	events engine.Observer
+	// config is the layer set by flags, applied over userConfig and each
+	// repo's config file.
+	config     engine.Config
+	userConfig engine.Config
+}
+
+// engineOptions returns the options for cleaning the repo at repoPath: the
+// user config, then the repo's config file, then flags.
+func (o cliOptions) engineOptions(repoPath string) (engine.Options, error) {
+	repoConfig, err := engine.LoadConfig(filepath.Join(repoPath, engine.RepoConfigFile))
+	if err != nil {
+		return engine.Options{}, err
+	}
+	// A cloned repo must not be able to run commands on this machine, so
+	// it may only name plugins that are installed on PATH.
+	if !repoConfig.Hooks.Empty() {
+		return engine.Options{}, fmt.Errorf("%s: hooks are only read from the user config", engine.RepoConfigFile)
+	}
+	for _, p := range repoConfig.Plugins {
+		if strings.Contains(p, "/") {
+			return engine.Options{}, fmt.Errorf("%s: plugin %q: only plugins on PATH may be named in a repo config", engine.RepoConfigFile, p)
+		}
+	}
+	opts, err := o.userConfig.Merge(repoConfig).Merge(o.config).Options()
+	if err != nil {
+		return opts, fmt.Errorf("invalid config: %w", err)
+	}
+	opts.Auto = o.auto
+	opts.Offline = o.offline
+	return opts, nil
+}
+
+func (o cliOptions) jsonOutput() bool {
+	return o.output == "json"
+}
+
+// eventsToStdout reports whether the event stream is written to stdout.
+func (o cliOptions) eventsToStdout() bool {
+	return o.eventsFmt != "" && o.eventsFile == ""
+}
+
+// showUI reports whether the styled UI may write to stdout.
+func (o cliOptions) showUI() bool {
+	return !o.jsonOutput() && !o.eventsToStdout()
+}
+
+// flagValue returns the value of a --name value or --name=value flag at
+// argv[*i], advancing *i past a separate value argument.
+func flagValue(argv []string, i *int, name string) (string, bool) {
+	a := argv[*i]
+	if v, ok := strings.CutPrefix(a, name+"="); ok {
+		return v, true
+	}
+	if a != name {
+		return "", false
+	}
+	if *i+1 >= len(argv) {
+		fmt.Fprintf(os.Stderr, "%s requires a value\n", name)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	*i++
+	return argv[*i], true
+}
+
 func main() {
-	// Parse --auto flag from any position in args.
-	auto := false
+	// Parse flags from any position in args.
+	opts := cliOptions{output: "text", nonInteractive: "fail"}
 	var args []string
-	for _, a := range os.Args[1:] {
-		if a == "--auto" {
-			auto = true
+	argv := os.Args[1:]
+	for i := 0; i < len(argv); i++ {
+		if argv[i] == "--auto" {
+			opts.auto = true
+		} else if v, ok := flagValue(argv, &i, "--auto-policy"); ok {
+			opts.config.AutoPolicy = strings.Split(v, ",")
+		} else if v, ok := flagValue(argv, &i, "--older-than"); ok {
+			opts.config.OlderThan = v
+		} else if v, ok := flagValue(argv, &i, "--protect"); ok {
+			opts.config.Protected = append(opts.config.Protected, v)
+		} else if v, ok := flagValue(argv, &i, "--plugin"); ok {
+			opts.config.Plugins = append(opts.config.Plugins, v)
+		} else if v, ok := flagValue(argv, &i, "--only"); ok {
+			opts.config.Only = strings.Split(v, ",")
+		} else if v, ok := flagValue(argv, &i, "--skip"); ok {
+			opts.config.Skip = strings.Split(v, ",")
+		} else if v, ok := flagValue(argv, &i, "--timeout"); ok {
+			step, d, found := strings.Cut(v, "=")
+			if !found {
+				fmt.Fprintf(os.Stderr, "--timeout wants step=duration, got %q\n", v)
+				fmt.Fprint(os.Stderr, usage)
+				os.Exit(1)
+			}
+			if opts.config.Timeouts == nil {
+				opts.config.Timeouts = make(map[string]string)
+			}
+			opts.config.Timeouts[step] = d
+		} else if v, ok := flagValue(argv, &i, "--retries"); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "--retries wants a number, got %q\n", v)
+				fmt.Fprint(os.Stderr, usage)
+				os.Exit(1)
+			}
+			opts.config.Retries = &n
+		} else if argv[i] == "--offline" {
+			opts.offline = true
+		} else if argv[i] == "--checklist" {
+			opts.checklist = true
+		} else if argv[i] == "--plain" {
+			opts.plain = true
+		} else if v, ok := flagValue(argv, &i, "--non-interactive"); ok {
+			opts.nonInteractive = v
+		} else if v, ok := flagValue(argv, &i, "--output"); ok {
+			opts.output = v
+		} else if v, ok := flagValue(argv, &i, "--events"); ok {
+			opts.eventsFmt = v
+		} else if v, ok := flagValue(argv, &i, "--events-file"); ok {
+			opts.eventsFile = v
 		} else {
-			args = append(args, a)
+			args = append(args, argv[i])
+		}
+	}
+	if opts.output != "text" && opts.output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", opts.output)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if opts.nonInteractive != "fail" && opts.nonInteractive != "auto" {
+		fmt.Fprintf(os.Stderr, "unknown --non-interactive mode %q\n", opts.nonInteractive)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	userConfigPath, err := engine.UserConfigPath()
+	if err == nil {
+		opts.userConfig, err = engine.LoadConfig(userConfigPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Catch bad flags and user config before touching any repo.
+	if _, err := opts.userConfig.Merge(opts.config).Options(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if opts.eventsFile != "" && opts.eventsFmt == "" {
+		opts.eventsFmt = "ndjson"
+	}
+	if opts.eventsFmt != "" && opts.eventsFmt != "ndjson" {
+		fmt.Fprintf(os.Stderr, "unknown events format %q\n", opts.eventsFmt)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if opts.jsonOutput() && opts.eventsToStdout() {
+		fmt.Fprintln(os.Stderr, "--output json and --events both write to stdout; use --events-file")
+		os.Exit(1)
+	}
+
+	var events *engine.NDJSONObserver
+	var eventsFile *os.File
+	if opts.eventsFmt != "" {
+		w := os.Stdout
+		if opts.eventsFile != "" {
+			eventsFile, err = os.Create(opts.eventsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "creating events file: %v\n", err)
+				os.Exit(1)
+			}
+			w = eventsFile
+		}
+		events = engine.NewNDJSONObserver(w)
+		opts.events = events
+	}
+	if plainTerminal() {
+		opts.plain = true
+	}
+	if !opts.showUI() || opts.plain {
+		// Keep stdout clean for machine-readable or logged output.
+		promptOutput = os.Stderr
+	}
+
+	// Ctrl+C interrupts the running command and ends the run with what was
+	// done so far. A second Ctrl+C exits at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+
+	if len(args) > 0 && args[0] == "why" {
+		why(ctx, args[1:], opts)
+		return
+	}
+	if !opts.auto && !canPrompt(promptOutput) {
+		if opts.nonInteractive == "auto" {
+			opts.auto = true
+		} else {
+			fmt.Fprintln(os.Stderr, "cannot prompt: not running in a terminal; use --auto or --non-interactive auto")
+			os.Exit(1)
 		}
 	}
 
 	if len(args) == 0 {
-		result := clean(".", true, auto)
-		if len(result.Errors) > 0 {
+		result := clean(ctx, repoRoot(ctx), true, opts)
+		if opts.jsonOutput() {
+			printJSON([]engine.Result{result})
+		}
+		ok := finishEvents(events, eventsFile)
+		if len(result.Errors) > 0 || !ok {
 			os.Exit(1)
 		}
 		return
@@ -32,66 +250,103 @@ func main() {
 		if len(args) > 1 {
 			dir = args[1]
 		}
-		if err := cleanAll(dir, auto); err != nil {
-			uiErr(err.Error())
+		results, err := cleanAll(ctx, dir, opts)
+		if err != nil {
+			if !opts.showUI() {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				uiErr(err.Error())
+			}
+			finishEvents(events, eventsFile)
+			os.Exit(1)
+		}
+		switch {
+		case opts.jsonOutput():
+			printJSON(results)
+		case !opts.showUI() || len(results) == 0:
+		case opts.plain:
+			plainSummary(results)
+		default:
+			uiSummary(results)
+		}
+		if !finishEvents(events, eventsFile) {
 			os.Exit(1)
 		}
 	default:
-		fmt.Fprintf(os.Stderr, "Usage: tidygit [--auto] [all [dir]]\n")
+		fmt.Fprint(os.Stderr, usage)
 		os.Exit(1)
 	}
 }
 
-func cleanAll(dir string, auto bool) error {
-	absDir, err := filepath.Abs(dir)
+// repoRoot returns the top-level directory of the repo containing the
+// current directory, so that its config file applies when tidygit is run
+// from a subdirectory. Outside a repo it returns "." and leaves reporting
+// that to the run.
+func repoRoot(ctx context.Context) string {
+	root, err := engine.RepoRoot(ctx, ".")
 	if err != nil {
-		return fmt.Errorf("resolving path %s: %w", dir, err)
+		return "."
 	}
+	return root
+}
 
-	entries, err := os.ReadDir(absDir)
+// finishEvents closes the --events output, if any, and reports whether every
+// event was written. A full disk or a closed pipe is reported on stderr.
+func finishEvents(events *engine.NDJSONObserver, file *os.File) bool {
+	if events == nil {
+		return true
+	}
+	err := events.Err()
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("reading directory %s: %w", absDir, err)
+		fmt.Fprintf(os.Stderr, "writing events: %v\n", err)
+		return false
 	}
+	return true
+}
 
-	// Collect repo paths and names upfront.
-	var repoPaths []string
-	var repoNames []string
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		repoPath := filepath.Join(absDir, entry.Name())
-		gitDir := filepath.Join(repoPath, ".git")
-		info, err := os.Stat(gitDir)
-		if err != nil || !info.IsDir() {
-			continue
-		}
-		repoPaths = append(repoPaths, repoPath)
-		repoNames = append(repoNames, entry.Name())
+func cleanAll(ctx context.Context, dir string, opts cliOptions) ([]engine.Result, error) {
+	repoPaths, err := engine.FindRepos(dir)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(repoPaths) == 0 {
-		fmt.Println("No git repositories found.")
-		return nil
+		if opts.showUI() {
+			fmt.Println("No git repositories found.")
+		}
+		return []engine.Result{}, nil
 	}
 
-	var results []repoResult
+	if opts.showUI() && !opts.plain {
+		return runApp(ctx, repoPaths, opts)
+	}
 
+	var results []engine.Result
 	for i, repoPath := range repoPaths {
-		uiClearScreen()
-		uiBrand()
-		uiProgressSpinner(i+1, len(repoPaths), repoNames[i])
-
-		results = append(results, clean(repoPath, false, auto))
-
-		// Always stop the progress spinner before next iteration,
-		// even if clean() returned early without stopping it.
-		uiStopProgress()
+		if opts.showUI() {
+			fmt.Printf("[%d/%d] %s\n", i+1, len(repoPaths), filepath.Base(repoPath))
+		}
+		result := clean(ctx, repoPath, false, opts)
+		results = append(results, result)
+		if result.Quit || ctx.Err() != nil {
+			break
+		}
 	}
 
-	// Final screen: summary only
-	uiClearScreen()
-	uiSummary(results)
+	return results, nil
+}
 
-	return nil
+// printJSON writes results to stdout as an indented JSON array.
+func printJSON(results []engine.Result) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		fmt.Fprintf(os.Stderr, "encoding results: %v\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpurdon/tidygit/engine"
+)
+
+// plainObserver renders engine events as one plain line each, prefixed with
+// the repo name, for logs and non-terminal output. It never writes escape
+// sequences.
+type plainObserver struct{}
+
+func (plainObserver) Observe(e engine.Event) {
+	name := filepath.Base(e.Repo)
+	line := func(format string, args ...any) {
+		fmt.Printf("%s: %s\n", name, fmt.Sprintf(format, args...))
+	}
+
+	switch e.Type {
+	case engine.EventRepoStarted:
+		if e.Branch != "" && e.Message != "" {
+			line("started (default branch %s, %s)", e.Branch, e.Message)
+		} else if e.Branch != "" {
+			line("started (default branch %s)", e.Branch)
+		} else {
+			line("started")
+		}
+	case engine.EventUncommitted:
+		line("uncommitted changes detected")
+	case engine.EventReset:
+		line("reset to HEAD")
+	case engine.EventRenamed:
+		line("renamed %s to %s", e.Message, e.Branch)
+	case engine.EventHeadSet:
+		line("set %s/HEAD to %s", e.Message, e.Branch)
+	case engine.EventSwitched:
+		line("switched to %s", e.Branch)
+	case engine.EventFetched:
+		line("fetched (pruned remotes)")
+	case engine.EventPulled:
+		line("pulled %s (%s)", e.Branch, e.Message)
+	case engine.EventPRsLoaded:
+		if e.Message != "" {
+			line("found %d PR(s) (%s)", e.Count, e.Message)
+		} else {
+			line("found %d PR(s)", e.Count)
+		}
+	case engine.EventWorktreesListed:
+		line("%d worktree(s)", e.Count)
+	case engine.EventBranchesListed:
+		line("%d branch(es)", e.Count)
+	case engine.EventWorktreeFound:
+		text := "worktree " + e.Worktree
+		if e.Branch != "" {
+			text += " (branch: " + e.Branch + ")"
+		}
+		line("%s%s%s", text, plainPR(e.PR), plainClass(e.Class))
+	case engine.EventBranchFound:
+		line("branch %s%s%s", e.Branch, plainPR(e.PR), plainClass(e.Class))
+	case engine.EventWorktreeRemoved:
+		line("removed worktree %s", e.Worktree)
+	case engine.EventBranchDeleted:
+		line("deleted branch %s (was %s)", e.Branch, e.SHA)
+	case engine.EventSkipped:
+		switch {
+		case e.Worktree != "":
+			line("kept worktree %s", e.Worktree)
+		case e.Branch != "":
+			line("kept branch %s", e.Branch)
+		case e.Step == "repo":
+			line("skipped the rest of this repo")
+		default:
+			line("skipped %s", e.Step)
+		}
+	case engine.EventRemembered:
+		line("keeping branch %s %s", e.Branch, e.Message)
+	case engine.EventRetrying:
+		line("%s attempt %d failed, %s", e.Step, e.Count, e.Message)
+	case engine.EventPluginAction:
+		line("%s: %s", e.Step, e.Message)
+	case engine.EventHook:
+		line("running %s hook: %s", e.Step, e.Message)
+	case engine.EventVetoed:
+		line("kept branch %s: %s", e.Branch, e.Message)
+	case engine.EventError:
+		line("error: %s", e.Message)
+	case engine.EventQuit:
+		line("quit requested, stopping")
+	case engine.EventRepoFinished:
+		line("done")
+	}
+}
+
+func plainPR(pr *engine.PR) string {
+	if pr == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [PR #%d %s: %s %s]", pr.Number, strings.ToLower(pr.State), pr.Title, pr.URL)
+}
+
+// plainClass formats a branch classification for a plain line.
+func plainClass(c *engine.Classification) string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [%s: %s]", c.Status, strings.Join(c.Reasons, "; "))
+}
+
+// plainSummary prints one line per repo followed by the totals.
+func plainSummary(results []engine.Result) {
+	var removed, deleted, kept, errs int
+	for _, r := range results {
+		status := "ok"
+		if len(r.Errors) > 0 {
+			status = fmt.Sprintf("%d error(s)", len(r.Errors))
+		}
+		var plugins string
+		for _, c := range pluginCounts(r) {
+			plugins += fmt.Sprintf(", %d %s", c.n, c.label)
+		}
+		fmt.Printf("summary: %s: %d wt removed, %d wt kept, %d br deleted, %d br kept, %d pr(s)%s, %s\n",
+			r.Name, r.WorktreesRemoved, r.WorktreesSkipped, r.BranchesDeleted, r.BranchesSkipped, r.PRsFound, plugins, status)
+		removed += r.WorktreesRemoved
+		deleted += r.BranchesDeleted
+		kept += r.WorktreesSkipped + r.BranchesSkipped
+		errs += len(r.Errors)
+	}
+	fmt.Printf("summary: %d repo(s), %d worktree(s) removed, %d branch(es) deleted, %d kept, %d error(s)\n",
+		len(results), removed, deleted, kept, errs)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// isTerminal reports whether w is a file attached to a terminal.
+func isTerminal(w any) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(f.Fd())
+}
+
+// plainTerminal reports whether output should use the plain, line-oriented
+// renderer: when stdout or stdin is not a terminal (cron, CI, pipes) or
+// TERM=dumb. The full-screen app needs both.
+func plainTerminal() bool {
+	return !isTerminal(os.Stdout) || !isTerminal(os.Stdin) || os.Getenv("TERM") == "dumb"
+}
+
+// canPrompt reports whether interactive prompts can read keys from stdin and
+// draw to out.
+func canPrompt(out io.Writer) bool {
+	return isTerminal(os.Stdin) && isTerminal(out)
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"charm.land/lipgloss/v2"
+	"github.com/kpurdon/tidygit/engine"
 )
 
 var (
@@ -37,106 +40,52 @@ var (
 			Padding(1, 2)
 )
 
-// Package-level progress spinner — stopped before any bubbletea prompt.
-var stopProgress func()
-
 func uiBrand() {
 	fmt.Println()
-	lipgloss.Println(
-		brandStyle.Render("  git tidy") +
-			brandDim.Render(" by kp"),
-	)
+	lipgloss.Println(brandLine())
 }
 
-// uiProgress renders a static progress counter (no spinner).
-func uiProgress(current, total int, repoName string) {
-	counter := dimStyle.Render(fmt.Sprintf("  [%d/%d]", current, total))
-	name := sectionStyle.Render(" " + repoName)
-	lipgloss.Println(counter + name)
-	lipgloss.Println(dimStyle.Render("  " + strings.Repeat("─", 40)))
+func brandLine() string {
+	return brandStyle.Render("  git tidy") + brandDim.Render(" by kp")
 }
 
-// uiProgressSpinner renders a progress counter with animated spinner on row 3.
-// Must be stopped with uiStopProgress() before any interactive prompt.
-func uiProgressSpinner(current, total int, repoName string) {
-	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-	var once sync.Once
-	done := make(chan struct{})
+// The *Line helpers return styled output lines, so the line-by-line output
+// and the full-screen app render events the same way.
 
-	// Row 3: after clear (row 1 = blank from uiBrand, row 2 = brand text, row 3 = progress)
-	const row = 3
-
-	// Print initial static line + divider
-	counter := dimStyle.Render(fmt.Sprintf("  [%d/%d]", current, total))
-	spinner := okStyle.Render(frames[0])
-	name := sectionStyle.Render(" " + repoName)
-	lipgloss.Println(counter + " " + spinner + name)
-	lipgloss.Println(dimStyle.Render("  " + strings.Repeat("─", 40)))
-
-	go func() {
-		i := 1
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				line := counter + " " + okStyle.Render(frames[i%len(frames)]) + name
-				// Save cursor, move to row 3, clear line, write, restore cursor
-				lipgloss.Printf("\033[s\033[%d;1H\033[K%s\033[u", row, line)
-				i++
-				time.Sleep(80 * time.Millisecond)
-			}
-		}
-	}()
-
-	stopProgress = func() {
-		once.Do(func() {
-			close(done)
-			// Replace spinner with check mark
-			line := counter + " " + okStyle.Render("✓") + name
-			lipgloss.Printf("\033[s\033[%d;1H\033[K%s\033[u", row, line)
-		})
-	}
-}
-
-// uiStopProgress stops the progress spinner if running.
-func uiStopProgress() {
-	if stopProgress != nil {
-		stopProgress()
-		stopProgress = nil
-	}
+func sectionLine(text string) string {
+	return sectionStyle.Render("  " + text)
 }
 
-func uiSection(text string) {
-	fmt.Println()
-	lipgloss.Println(sectionStyle.Render("  " + text))
-	fmt.Println()
+func okLine(text string) string {
+	return "  " + okStyle.Render("✓") + " " + text
 }
 
-func uiOK(text string) {
-	lipgloss.Println("  " + okStyle.Render("✓") + " " + text)
+func uiErr(text string) {
+	lipgloss.Println(errLine(text))
 }
 
-func uiErr(text string) {
-	lipgloss.Println("  " + errStyle.Render("✗") + " " + text)
+func errLine(text string) string {
+	return "  " + errStyle.Render("✗") + " " + text
 }
 
-func uiWarn(text string) {
-	lipgloss.Println("  " + warnStyle.Render("!") + " " + text)
+func warnLine(text string) string {
+	return "  " + warnStyle.Render("!") + " " + text
 }
 
-func uiItem(text string) {
-	lipgloss.Println("  " + itemStyle.Render("▸") + " " + text)
+func itemLine(text string) string {
+	return "  " + itemStyle.Render("▸") + " " + text
 }
 
-func uiDim(text string) {
-	lipgloss.Println("  " + dimStyle.Render(text))
+func dimLine(text string) string {
+	return "  " + dimStyle.Render(text)
 }
 
-func uiPR(pr PR) {
+func prLines(pr engine.PR) []string {
 	sep := dimStyle.Render(" · ")
-	lipgloss.Println("    " + prStyle.Render(fmt.Sprintf("PR #%d", pr.Number)) + sep + styledPRState(pr.State) + sep + prStyle.Render(pr.Title))
-	lipgloss.Println("    " + prURLStyle.Render(pr.URL))
+	return []string{
+		"    " + prStyle.Render(fmt.Sprintf("PR #%d", pr.Number)) + sep + styledPRState(pr.State) + sep + prStyle.Render(pr.Title),
+		"    " + prURLStyle.Render(pr.URL),
+	}
 }
 
 func styledPRState(state string) string {
@@ -153,18 +102,81 @@ func styledPRState(state string) string {
 	}
 }
 
-func uiSkipped() {
-	lipgloss.Println("    " + dimStyle.Render("· Skipped"))
+// statusLabels are the short badge labels for each branch status.
+var statusLabels = map[engine.Status]string{
+	engine.StatusProtected:    "protected",
+	engine.StatusUnpushed:     "unpushed",
+	engine.StatusDirty:        "uncommitted",
+	engine.StatusOpenPR:       "open PR",
+	engine.StatusMergedPR:     "merged PR",
+	engine.StatusNew:          "new",
+	engine.StatusAncestor:     "in default",
+	engine.StatusSquashMerged: "squashed",
+	engine.StatusUpstreamGone: "upstream gone",
+	engine.StatusUnknown:      "unknown",
 }
 
-func uiClearScreen() {
-	fmt.Print("\033[2J\033[H")
+// statusBadgeWidth is the width of the longest status label.
+const statusBadgeWidth = 13
+
+// statusBadge renders a colored badge for s, padded to width characters.
+func statusBadge(s engine.Status, width int) string {
+	label := fmt.Sprintf("%-*s", width, statusLabels[s])
+	switch {
+	case s.Deletable():
+		return lipgloss.NewStyle().Foreground(purpleColor).Render(label)
+	case s == engine.StatusOpenPR:
+		return okStyle.Render(label)
+	case s == engine.StatusUnpushed, s == engine.StatusDirty:
+		return errStyle.Render(label)
+	case s == engine.StatusProtected:
+		return sectionStyle.Render(label)
+	default:
+		return dimStyle.Render(label)
+	}
 }
 
-func uiDone() {
-	fmt.Println()
-	lipgloss.Println("  " + okStyle.Render("✓ Done"))
-	fmt.Println()
+// classLines renders a classification as a badge followed by its reasons.
+func classLines(c engine.Classification) []string {
+	badge := statusBadge(c.Status, 0)
+	if c.Stale {
+		badge += " " + warnStyle.Render("stale")
+	}
+	if c.Kept != "" {
+		badge += " " + sectionStyle.Render("kept")
+	}
+	return []string{"    " + badge + dimStyle.Render(" · "+strings.Join(c.Reasons, " · "))}
+}
+
+// formatAge renders the time since t compactly, e.g. "3d" or "2y".
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := time.Since(t)
+	day := 24 * time.Hour
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < day:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 14*day:
+		return fmt.Sprintf("%dd", int(d/day))
+	case d < 60*day:
+		return fmt.Sprintf("%dw", int(d/(7*day)))
+	case d < 365*day:
+		return fmt.Sprintf("%dmo", int(d/(30*day)))
+	default:
+		return fmt.Sprintf("%dy", int(d/(365*day)))
+	}
+}
+
+func skippedLine() string {
+	return "    " + dimStyle.Render("· Skipped")
+}
+
+func doneLine() string {
+	return "  " + okStyle.Render("✓ Done")
 }
 
 // styledKept renders a count in green (kept/active = good).
@@ -185,7 +197,25 @@ func styledRemoved(n int, label string) string {
 	return errStyle.Render(s + " " + label)
 }
 
-func uiSummary(results []repoResult) {
+// pluginCount is a count reported by a plugin step.
+type pluginCount struct {
+	label string
+	n     int
+}
+
+// pluginCounts returns the counts reported by r's plugin steps, in step
+// order and then by label.
+func pluginCounts(r engine.Result) []pluginCount {
+	var counts []pluginCount
+	for _, p := range r.Plugins {
+		for _, label := range slices.Sorted(maps.Keys(p.Counts)) {
+			counts = append(counts, pluginCount{label: label, n: p.Counts[label]})
+		}
+	}
+	return counts
+}
+
+func uiSummary(results []engine.Result) {
 	uiBrand()
 	fmt.Println()
 
@@ -231,6 +261,9 @@ func uiSummary(results []repoResult) {
 			styledKept(r.PRsFound, "pr(s)"),
 		)
 
+		for _, c := range pluginCounts(r) {
+			detail += sep + styledRemoved(c.n, c.label)
+		}
 		if len(r.Errors) > 0 {
 			detail += sep + errStyle.Render(fmt.Sprintf("%d error(s)", len(r.Errors)))
 		}
@@ -281,7 +314,7 @@ func uiSummary(results []repoResult) {
 	for _, r := range results {
 		for _, e := range r.Errors {
 			prefix := errStyle.Render("  "+r.Name) + dimStyle.Render(": ")
-			errLines = append(errLines, prefix+errWrapStyle.Render(e))
+			errLines = append(errLines, prefix+errWrapStyle.Render(e.Message))
 		}
 	}
 	if len(errLines) > 0 {
@@ -293,8 +326,10 @@ func uiSummary(results []repoResult) {
 	fmt.Println()
 }
 
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
 func uiSpinner(text string) func() {
-	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	frames := spinnerFrames
 	var once sync.Once
 	done := make(chan struct{})
 
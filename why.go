@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"charm.land/lipgloss/v2"
+	"github.com/kpurdon/tidygit/engine"
+)
+
+// why prints how branch in the current repo is classified and what a
+// cleanup run would do with it.
+func why(ctx context.Context, args []string, opts cliOptions) {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	branch := args[0]
+
+	repoPath := repoRoot(ctx)
+	engineOpts, err := opts.engineOptions(repoPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	class, pr, err := engine.ClassifyBranch(ctx, repoPath, branch, engineOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	autoDelete := engineOpts.Policy.Deletes(class, pr)
+
+	if opts.jsonOutput() {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Branch     string `json:"branch"`
+			AutoDelete bool   `json:"auto_delete"`
+			engine.Classification
+		}{branch, autoDelete, class}); err != nil {
+			fmt.Fprintf(os.Stderr, "encoding classification: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	verdict := "--auto would keep it"
+	if autoDelete {
+		verdict = "--auto would delete it"
+	}
+	verdict += " (policy " + engineOpts.Policy.String() + ")"
+
+	if opts.plain {
+		status := string(class.Status)
+		if class.Stale {
+			status += " (stale)"
+		}
+		fmt.Printf("%s: %s\n", branch, status)
+		for _, reason := range class.Reasons {
+			fmt.Printf("  - %s\n", reason)
+		}
+		fmt.Printf("  %s\n", verdict)
+		return
+	}
+
+	fmt.Println()
+	badge := statusBadge(class.Status, 0)
+	if class.Stale {
+		badge += " " + warnStyle.Render("stale")
+	}
+	lipgloss.Println(itemLine(branch + " " + badge))
+	for i, reason := range class.Reasons {
+		if i == 0 {
+			lipgloss.Println("    " + reason)
+		} else {
+			lipgloss.Println(dimLine("  " + reason))
+		}
+	}
+	fmt.Println()
+	lipgloss.Println(dimLine(verdict))
+	fmt.Println()
+}